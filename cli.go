@@ -1,15 +1,21 @@
 package cli
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"os"
 	"path"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 // CLI is main CLI application definition. It has a name, description, author
@@ -17,15 +23,41 @@ import (
 // to File instances to which standard output or errors are printed (named
 // respectively stdout and stderr).
 type CLI struct {
-	name        string
-	desc        string
-	author      string
-	cmds        map[string]*CLICmd
-	parsedFlags map[string]string
-	parsedArgs  map[string]string
-	stdout      *os.File
-	stderr      *os.File
-	stdin       *os.File
+	name           string
+	desc           string
+	author         string
+	cmds           map[string]*CLICmd
+	cmdsOrder      []string
+	categories     []*category
+	unsortedCmds   bool
+	cmd            *CLICmd
+	globalFlags    map[string]*CLIFlag
+	parsedFlags    map[string]string
+	parsedArgs     map[string]string
+	flagSources    map[string]FlagSource
+	stdout         *os.File
+	stderr         *os.File
+	stdin          *os.File
+	stdinReader    *bufio.Reader
+	interactive    *bool
+	noInput        bool
+	responseFiles  bool
+	logger         *slog.Logger
+	loggingFlags   bool
+	version        string
+	versionFlag    bool
+	helpTopics     map[string]*helpTopic
+	shellCmds      map[string]*shellCmd
+	shellPrompt    string
+	inShell        bool
+	defErrs        []error
+	exitCodes      map[ErrorKind]int
+	errFormat      func(error) string
+	cleanups       []func(ctx context.Context)
+	cleanupTimeout time.Duration
+	hooks          hooks
+	dryRun         bool
+	passthrough    []string
 }
 
 // GetName returns CLI name.
@@ -49,6 +81,11 @@ func (c *CLI) AttachCmd(cmd *CLICmd) {
 	if c.cmds == nil {
 		c.cmds = make(map[string]*CLICmd)
 	}
+	if o, ok := c.cmds[n]; ok && o != cmd {
+		c.defErrs = append(c.defErrs, &DefinitionError{Command: n, Reason: "command is added more than once"})
+	} else if !ok {
+		c.cmdsOrder = append(c.cmdsOrder, n)
+	}
 	c.cmds[n] = cmd
 }
 
@@ -57,6 +94,20 @@ func (c *CLI) GetCmd(k string) *CLICmd {
 	return c.cmds[k]
 }
 
+// FindCmd returns instance of CLICmd that has name or alias k. It returns
+// nil when there is no such command.
+func (c *CLI) FindCmd(k string) *CLICmd {
+	if cmd, ok := c.cmds[k]; ok {
+		return cmd
+	}
+	for _, n := range c.GetSortedCmds() {
+		if c.cmds[n].HasName(k) {
+			return c.cmds[n]
+		}
+	}
+	return nil
+}
+
 // GetStdout returns stdout property.
 func (c *CLI) GetStdout() *os.File {
 	return c.stdout
@@ -67,8 +118,12 @@ func (c *CLI) GetStderr() *os.File {
 	return c.stderr
 }
 
-// GetStdin returns stdin property.
+// GetStdin returns stdin property. It is os.Stdin unless it was set with
+// SetStdin.
 func (c *CLI) GetStdin() *os.File {
+	if c.stdin == nil {
+		return os.Stdin
+	}
 	return c.stdin
 }
 
@@ -85,27 +140,140 @@ func (c *CLI) GetSortedCmds() []string {
 
 // PrintHelp prints usage info to stdout file.
 func (c *CLI) PrintHelp() {
-	fmt.Fprintf(c.stdout, c.name+" by "+c.author+"\n"+c.desc+"\n\n")
-	fmt.Fprintf(c.stdout, "Usage: "+path.Base(os.Args[0])+" [FLAGS] COMMAND\n\n")
-	fmt.Fprintf(c.stdout, "Commands:\n")
-
+	fmt.Fprint(c.stdout, c.name+" by "+c.author+"\n"+c.desc+"\n\n")
+	fmt.Fprint(c.stdout, "Usage: "+path.Base(os.Args[0])+" [FLAGS] COMMAND\n\n")
 	w := new(tabwriter.Writer)
 	w.Init(c.stdout, 8, 8, 0, '\t', 0)
-	for _, n := range c.GetSortedCmds() {
-		cmd := c.GetCmd(n)
-		fmt.Fprintf(w, "  "+n+"\t"+cmd.GetDesc()+"\n")
+	c.printHelpCmds(w)
+	c.printShellCmdsHelp(w)
+
+	gs := ""
+	for _, f := range c.getGlobalFlagList() {
+		if !f.IsHidden() && !f.IsDeprecated() {
+			gs += f.GetHelpLine()
+		}
+	}
+	if gs != "" {
+		fmt.Fprintf(w, "\nGlobal flags: \n")
+		fmt.Fprint(w, gs)
+		w.Flush()
 	}
-	w.Flush()
+	c.printHelpTopics(w)
 
-	fmt.Fprintf(c.stdout, "\nRun '"+path.Base(os.Args[0])+" COMMAND --help' for more information on a command.\n")
+	fmt.Fprint(c.stdout, "\nRun '"+path.Base(os.Args[0])+" help COMMAND' or '"+path.Base(os.Args[0])+" COMMAND --help' for more information on a command.\n")
 }
 
-// PrintInvalidCmd prints invalid command error to stderr file.
+// PrintInvalidCmd prints invalid command error to stderr file. When there is
+// a command with similar name, it is suggested.
 func (c *CLI) PrintInvalidCmd(cmd string) {
-	fmt.Fprintf(c.stderr, "Invalid command: "+cmd+"\n\n")
+	c.printError(c.newUnknownCommandError(cmd))
+	fmt.Fprintf(c.stderr, "\n")
 	c.PrintHelp()
 }
 
+// newUnknownCommandError returns UnknownCommandError for cmd with a suggestion
+// of a visible command with similar name.
+func (c *CLI) newUnknownCommandError(cmd string) *UnknownCommandError {
+	return &UnknownCommandError{Command: cmd, Suggestion: suggest(cmd, c.getVisibleCmdNames())}
+}
+
+// getVisibleCmdNames returns names and aliases of commands that are not
+// hidden.
+func (c *CLI) getVisibleCmdNames() []string {
+	var ns []string
+	for _, n := range c.GetSortedCmds() {
+		cmd := c.GetCmd(n)
+		if cmd.IsHidden() {
+			continue
+		}
+		ns = append(ns, n)
+		ns = append(ns, cmd.GetAliases()...)
+	}
+	return ns
+}
+
+// SetExitCode sets exit code that is returned when an error of kind k occurs.
+// By default, all errors result in exit code of 1.
+func (c *CLI) SetExitCode(k ErrorKind, code int) {
+	if c.exitCodes == nil {
+		c.exitCodes = make(map[ErrorKind]int)
+	}
+	c.exitCodes[k] = code
+}
+
+// GetExitCode returns exit code for err. Code from ExitError takes precedence
+// over the one mapped to error kind.
+func (c *CLI) GetExitCode(err error) int {
+	var ee *ExitError
+	if errors.As(err, &ee) {
+		return ee.Code
+	}
+	if code, ok := c.exitCodes[GetErrorKind(err)]; ok {
+		return code
+	}
+	return 1
+}
+
+// SetErrorFormatter sets function that formats errors before they are
+// printed to stderr, eg. to translate messages of the package errors.
+func (c *CLI) SetErrorFormatter(fn func(err error) string) {
+	c.errFormat = fn
+}
+
+// printError prints err to stderr file, along with a suggestion if err
+// contains one.
+func (c *CLI) printError(err error) {
+	if c.errFormat != nil {
+		fmt.Fprintln(c.stderr, c.errFormat(err))
+		return
+	}
+	fmt.Fprintln(c.stderr, "ERROR: "+err.Error())
+	var s string
+	var ce *UnknownCommandError
+	var fe *UnknownFlagError
+	if errors.As(err, &ce) {
+		s = ce.Suggestion
+	} else if errors.As(err, &fe) {
+		s = fe.Suggestion
+	}
+	if s != "" {
+		fmt.Fprintln(c.stderr, "Did you mean '"+s+"'?")
+	}
+}
+
+// newFlagSetError converts error returned by parsing args with fset. The
+// flag that caused it is found in args that fset did not parse. Unknown flags
+// get a suggestion of a similar flag from fs when there is any and a flag
+// without a value results in MissingFlagError.
+func newFlagSetError(err error, fset *flag.FlagSet, args []string, fs []*CLIFlag) error {
+	i := len(args) - len(fset.Args()) - 1
+	if i < 0 {
+		return err
+	}
+	if !strings.HasPrefix(args[i], "-") && i > 0 {
+		// value of the flag that is the previous arg
+		i--
+	}
+	n := strings.SplitN(strings.TrimLeft(args[i], "-"), "=", 2)[0]
+	ff := fset.Lookup(n)
+	if ff == nil {
+		p := "-"
+		if len(n) > 1 {
+			p = "--"
+		}
+		return &UnknownFlagError{Flag: p + n, Suggestion: suggestFlag(n, fs)}
+	}
+	if bf, ok := ff.Value.(interface{ IsBoolFlag() bool }); (ok && bf.IsBoolFlag()) || strings.Contains(args[i], "=") || i < len(args)-1 {
+		return err
+	}
+	for _, f := range fs {
+		if f.GetAlias() == n {
+			n = f.GetName()
+		}
+	}
+	return &MissingFlagError{Flag: n}
+}
+
 // AddCmd creates a new command with name n, description d and handler of f.
 // It creates instance of CLICmd, attaches it to CLI and returns it.
 func (c *CLI) AddCmd(n string, d string, f func(cli *CLI) int) *CLICmd {
@@ -114,91 +282,369 @@ func (c *CLI) AddCmd(n string, d string, f func(cli *CLI) int) *CLICmd {
 	return cmd
 }
 
+// AddCmdE creates a new command with name n, description d and handler of f
+// that returns an error. It creates instance of CLICmd, attaches it to CLI and
+// returns it.
+func (c *CLI) AddCmdE(n string, d string, f HandlerFunc) *CLICmd {
+	cmd := NewCLICmdE(n, d, f)
+	c.AttachCmd(cmd)
+	return cmd
+}
+
 // AddFlagToCmds adds a flag to all attached commands.
 // It creates CLIFlag instance and attaches it.
 func (c *CLI) AddFlagToCmds(n string, a string, hv string, d string, nf int32, fn func(*CLICmd)) {
-	for _, n := range c.GetSortedCmds() {
-		cmd := c.GetCmd(n)
+	for _, cn := range c.GetSortedCmds() {
+		cmd := c.GetCmd(cn)
 		flg := NewCLIFlag(n, a, hv, d, nf, fn)
 		cmd.AttachFlag(flg)
 	}
 }
 
+// AddGlobalFlag adds a flag that can be passed before or after name of any
+// command. Its value is available with Flag method, like value of any other
+// flag. Command flag with the same name takes precedence over global one.
+func (c *CLI) AddGlobalFlag(n string, a string, hv string, d string, nf int32, fn func(*CLICmd)) {
+	if c.globalFlags == nil {
+		c.globalFlags = make(map[string]*CLIFlag)
+	}
+	if _, ok := c.globalFlags[n]; ok {
+		c.defErrs = append(c.defErrs, &DefinitionError{Flag: "flag --" + n, Reason: "global flag is added more than once"})
+	}
+	c.globalFlags[n] = NewCLIFlag(n, a, hv, d, nf, fn)
+}
+
+// GetGlobalFlag returns instance of CLIFlag of global flag k.
+func (c *CLI) GetGlobalFlag(k string) *CLIFlag {
+	if f, ok := c.globalFlags[k]; ok {
+		return f
+	}
+	if k == NoInputFlag && c.hasPrompts() {
+		return noInputFlag
+	}
+	if k == OutputFlag && c.versionFlag {
+		return versionOutputFlag
+	}
+	return nil
+}
+
+// GetSortedGlobalFlags returns sorted list of global flag names.
+func (c *CLI) GetSortedGlobalFlags() []string {
+	fs := make([]string, 0, len(c.globalFlags))
+	for n := range c.globalFlags {
+		fs = append(fs, n)
+	}
+	if _, ok := c.globalFlags[NoInputFlag]; !ok && c.hasPrompts() {
+		fs = append(fs, NoInputFlag)
+	}
+	if _, ok := c.globalFlags[OutputFlag]; !ok && c.versionFlag {
+		fs = append(fs, OutputFlag)
+	}
+	sort.Strings(fs)
+	return fs
+}
+
+// getGlobalFlagList returns global flags sorted by name.
+func (c *CLI) getGlobalFlagList() []*CLIFlag {
+	var fs []*CLIFlag
+	for _, n := range c.GetSortedGlobalFlags() {
+		fs = append(fs, c.GetGlobalFlag(n))
+	}
+	return fs
+}
+
 // AddArg adds an argument to all attached commands.
 func (c *CLI) AddArgToCmds(n string, hv string, d string, nf int32) {
-	for _, n := range c.GetSortedCmds() {
-		cmd := c.GetCmd(n)
-		if cmd.argsIdx > 9 {
-			log.Fatal("Only 10 arguments are allowed")
-		}
+	for _, cn := range c.GetSortedCmds() {
+		cmd := c.GetCmd(cn)
 		arg := NewCLIFlag(n, "", hv, d, nf, nil)
 		cmd.AttachArg(arg)
 	}
 }
 
-// getFlagSetPtrs creates flagset instance, parses flags and returns list of
-// pointers to results of parsing the flags.
-func (c *CLI) getFlagSetPtrs(cmd *CLICmd) (map[string]interface{}, map[string]interface{}, []string) {
+// newFlagSet creates flagset instance that does not print anything.
+func newFlagSet() *flag.FlagSet {
 	fset := flag.NewFlagSet("flagset", flag.ContinueOnError)
 	// nothing should come out of flagset
 	fset.Usage = func() {}
 	fset.SetOutput(ioutil.Discard)
+	return fset
+}
 
+// defineFlags defines flags fs in flagset and returns pointers to results of
+// parsing their names and aliases. Names and aliases that are already defined
+// in flagset are skipped.
+func defineFlags(fset *flag.FlagSet, fs []*CLIFlag) (map[string]interface{}, map[string]interface{}) {
 	nptrs := make(map[string]interface{})
 	aptrs := make(map[string]interface{})
-	fs := cmd.GetSortedFlags()
-	for _, n := range fs {
-		f := cmd.GetFlag(n)
-		if f.IsRequireValue() {
-			nptrs[n] = fset.String(n, "", "")
-			aptrs[f.GetAlias()] = fset.String(f.GetAlias(), "", "")
-		} else if f.IsTypeBool() {
-			nptrs[n] = fset.Bool(n, false, "")
-			aptrs[f.GetAlias()] = fset.Bool(f.GetAlias(), false, "")
+	for _, f := range fs {
+		for i, n := range []string{f.GetName(), f.GetAlias()} {
+			if n == "" || fset.Lookup(n) != nil {
+				continue
+			}
+			ptrs := nptrs
+			if i == 1 {
+				ptrs = aptrs
+			}
+			if f.IsRequireValue() {
+				ptrs[n] = fset.String(n, "", "")
+			} else if f.IsTypeCount() {
+				v := new(countValue)
+				fset.Var(v, n, "")
+				ptrs[n] = v
+			} else if f.IsTypeBool() {
+				ptrs[n] = fset.Bool(n, false, "")
+			}
 		}
 	}
-	fset.Parse(os.Args[2:])
-	return nptrs, aptrs, fset.Args()
+	return nptrs, aptrs
 }
 
-// parseFlags iterates over flags and args and validates them.
-// In case of error it prints out to CLI stderr.
-func (c *CLI) parseFlags(cmd *CLICmd) int {
-	if c.parsedFlags == nil {
-		c.parsedFlags = make(map[string]string)
+// getFlagSetPtrs creates flagset instance with flags of the command and
+// global flags, parses args and returns pointers to results of parsing the
+// command flags and global flags. Error from parsing is returned as well.
+func (c *CLI) getFlagSetPtrs(cmd *CLICmd, args []string) (*flag.FlagSet, [4]map[string]interface{}, error) {
+	var ptrs [4]map[string]interface{}
+	fset := newFlagSet()
+	ptrs[0], ptrs[1] = defineFlags(fset, append(cmd.getFlagList(), getSecretFileFlags(cmd.getFlagList())...))
+	ptrs[2], ptrs[3] = defineFlags(fset, append(c.getGlobalFlagList(), getSecretFileFlags(c.getGlobalFlagList())...))
+	err := fset.Parse(args)
+	return fset, ptrs, err
+}
+
+// mapDeprecatedFlags prints a warning for each deprecated flag from fs that
+// was passed and sets its value to the replacement flag, which is found with
+// get, unless the replacement flag was passed as well. It is used for flags
+// of the command and for global flags. optrs contains pointers to values
+// passed on the other side of the command name, which are checked for the
+// replacement flag too.
+func (c *CLI) mapDeprecatedFlags(fs []*CLIFlag, get func(string) *CLIFlag, nptrs map[string]interface{}, aptrs map[string]interface{}, optrs [2]map[string]interface{}) {
+	for _, f := range fs {
+		n := f.GetName()
+		if !f.IsDeprecated() {
+			continue
+		}
+		nv, av := getFlagValues(f, nptrs, aptrs)
+		if nv == "" && av == "" {
+			continue
+		}
+		used := "--" + n
+		if nv == "" {
+			used = "-" + f.GetAlias()
+		}
+		s := "WARNING: Flag " + used + " is deprecated"
+		r := get(f.GetReplacedBy())
+		if r != nil {
+			s += ", use --" + r.GetName() + " instead"
+		}
+		fmt.Fprintln(c.stderr, s)
+		if r == nil {
+			continue
+		}
+		rnv, rav := getFlagValues(r, nptrs, aptrs)
+		onv, oav := getFlagValues(r, optrs[0], optrs[1])
+		if rnv != "" || rav != "" || onv != "" || oav != "" {
+			continue
+		}
+		v := av
+		if nv != "" {
+			v = nv
+		}
+		if p, ok := nptrs[r.GetName()].(*string); ok {
+			*p = v
+		}
+		if p, ok := nptrs[r.GetName()].(*bool); ok {
+			*p = true
+		}
 	}
+}
 
-	fs := cmd.GetSortedFlags()
-	nptrs, aptrs, args := c.getFlagSetPtrs(cmd)
+// getFlagValues returns values of flag f passed with its name and alias.
+// Bool flag has a value of "true" when it was passed.
+func getFlagValues(f *CLIFlag, nptrs map[string]interface{}, aptrs map[string]interface{}) (string, string) {
+	var nv, av string
+	if p, ok := nptrs[f.GetName()].(*bool); ok && *p {
+		nv = "true"
+	}
+	if p, ok := nptrs[f.GetName()].(*string); ok {
+		nv = *p
+	}
+	if p, ok := nptrs[f.GetName()].(*countValue); ok && *p > 0 {
+		nv = p.String()
+	}
+	if f.GetAlias() == "" {
+		return nv, av
+	}
+	if p, ok := aptrs[f.GetAlias()].(*countValue); ok && *p > 0 {
+		av = p.String()
+	}
+	if p, ok := aptrs[f.GetAlias()].(*bool); ok && *p {
+		av = "true"
+	}
+	if p, ok := aptrs[f.GetAlias()].(*string); ok {
+		av = *p
+	}
+	return nv, av
+}
+
+// promptIfMissing asks for value of required flag or argument f when it was
+// not passed and prompts are enabled. Otherwise, nv is returned.
+func (c *CLI) promptIfMissing(f *CLIFlag, isArg bool, nv string, av string) string {
+	if nv != "" || av != "" || !f.IsRequired() || !f.HasPrompt() || !c.IsInteractive() {
+		return nv
+	}
+	return c.promptValue(f, isArg)
+}
+
+// setFlagValue validates values nv and av passed with name and alias of flag
+// f and stores the result.
+func (c *CLI) setFlagValue(cmd *CLICmd, f *CLIFlag, nv string, av string) error {
+	n := f.GetName()
+	if c.flagSources == nil {
+		c.flagSources = make(map[string]FlagSource)
+	}
+	c.flagSources[n] = getFlagSource(nv, av)
+	if pv := c.promptIfMissing(f, false, nv, av); pv != nv {
+		c.flagSources[n] = SourcePrompt
+		nv = pv
+	}
+	if f.IsTypeCount() {
+		nc, _ := strconv.Atoi(nv)
+		ac, _ := strconv.Atoi(av)
+		c.parsedFlags[n] = strconv.Itoa(nc + ac)
+		return nil
+	}
+	if f.IsTypeBool() {
+		c.parsedFlags[n] = "false"
+		if nv == "true" || av == "true" {
+			c.parsedFlags[n] = "true"
+			f.ExecFn(cmd)
+		}
+		return nil
+	}
+
+	err := f.ValidateValue(false, nv, av)
+	if err != nil {
+		return err
+	}
+
+	c.parsedFlags[n] = av
+	if nv != "" {
+		c.parsedFlags[n] = nv
+	}
+	return nil
+}
+
+// isFlagPassed returns true when flag n of cmd or global flag n was passed,
+// ie. it has a value, it is bool flag that is set to true or count flag that
+// is greater than 0.
+func (c *CLI) isFlagPassed(cmd *CLICmd, n string) bool {
+	f := cmd.GetFlag(n)
+	if f == nil {
+		f = c.GetGlobalFlag(n)
+	}
+	if f != nil && f.IsTypeBool() {
+		return c.parsedFlags[n] == "true"
+	}
+	if f != nil && f.IsTypeCount() {
+		return c.parsedFlags[n] != "" && c.parsedFlags[n] != "0"
+	}
+	return c.parsedFlags[n] != ""
+}
 
-	for _, n := range fs {
-		f := cmd.GetFlag(n)
-		a := f.GetAlias()
+// parseFlags parses args and iterates over flags, global flags and args and
+// validates them. cmd should be a copy of the command as flags and arguments
+// are modified when rules are applied. gptrs contains pointers to values of
+// global flags that were passed before command name, and they are used when
+// global flag was not passed after it. First error that is found is
+// returned. flag.ErrHelp is returned when -h or --help was passed anywhere
+// before "--", except as a value of a flag, and *versionRequest when
+// --version was passed as a flag.
+func (c *CLI) parseFlags(cmd *CLICmd, args []string, gptrs [2]map[string]interface{}) error {
+	if c.parsedFlags == nil {
+		c.parsedFlags = make(map[string]string)
+	}
 
-		var nv string
-		var av string
-		if f.IsTypeBool() {
-			c.parsedFlags[n] = "false"
-			if *(nptrs[n]).(*bool) == true || *(aptrs[a]).(*bool) == true {
-				c.parsedFlags[n] = "true"
-				f.ExecFn(cmd)
+	fargs := args
+	fset, ptrs, err := c.getFlagSetPtrs(cmd, args)
+	if err == flag.ErrHelp || (err == nil && hasHelpArg(fset, args)) {
+		return flag.ErrHelp
+	}
+	if err != nil {
+		return newFlagSetError(err, fset, args, append(cmd.getFlagList(), c.getGlobalFlagList()...))
+	}
+	if vr := c.getVersionRequest(cmd, ptrs[2], ptrs[3], gptrs); vr != nil {
+		return vr
+	}
+	args = fset.Args()
+	err = c.resolveSecrets(cmd.getFlagList(), ptrs[0], ptrs[1])
+	if err == nil {
+		err = c.resolveSecrets(c.getGlobalFlagList(), ptrs[2], ptrs[3])
+	}
+	if err == nil {
+		err = c.resolveSecrets(c.getGlobalFlagList(), gptrs[0], gptrs[1])
+	}
+	if err == nil {
+		err = resolveFileValues(cmd.getFlagList(), ptrs[0], ptrs[1])
+	}
+	if err == nil {
+		err = resolveFileValues(c.getGlobalFlagList(), ptrs[2], ptrs[3])
+	}
+	if err == nil {
+		err = resolveFileValues(c.getGlobalFlagList(), gptrs[0], gptrs[1])
+	}
+	if err != nil {
+		return err
+	}
+	c.mapDeprecatedFlags(cmd.getFlagList(), cmd.GetFlag, ptrs[0], ptrs[1], [2]map[string]interface{}{})
+	c.mapDeprecatedFlags(c.getGlobalFlagList(), c.GetGlobalFlag, ptrs[2], ptrs[3], gptrs)
+	c.mapDeprecatedFlags(c.getGlobalFlagList(), c.GetGlobalFlag, gptrs[0], gptrs[1], [2]map[string]interface{}{ptrs[2], ptrs[3]})
+	value := func(n string) string {
+		var nv, av string
+		if f := cmd.GetFlag(n); f != nil {
+			nv, av = getFlagValues(f, ptrs[0], ptrs[1])
+		} else if f := c.GetGlobalFlag(n); f != nil {
+			nv, av = getFlagValues(f, ptrs[2], ptrs[3])
+			if nv == "" && av == "" {
+				nv, av = getFlagValues(f, gptrs[0], gptrs[1])
 			}
-			continue
 		}
+		if nv != "" {
+			return nv
+		}
+		return av
+	}
+	cmd.applyRules(value)
+	c.noInput = value(NoInputFlag) == "true"
 
-		nv = *(nptrs[n]).(*string)
-		av = *(aptrs[a]).(*string)
+	for _, f := range cmd.getFlagList() {
+		nv, av := getFlagValues(f, ptrs[0], ptrs[1])
+		err := c.setFlagValue(cmd, f, nv, av)
+		if err != nil {
+			return err
+		}
+	}
 
-		err := f.ValidateValue(false, nv, av)
+	for _, f := range c.getGlobalFlagList() {
+		if cmd.GetFlag(f.GetName()) != nil {
+			continue
+		}
+		nv, av := getFlagValues(f, ptrs[2], ptrs[3])
+		if nv == "" && av == "" {
+			nv, av = getFlagValues(f, gptrs[0], gptrs[1])
+		}
+		err := c.setFlagValue(cmd, f, nv, av)
 		if err != nil {
-			fmt.Fprintf(c.stderr, "ERROR: "+err.Error()+"\n")
-			cmd.PrintHelp(c)
-			return 1
+			return err
 		}
+	}
 
-		c.parsedFlags[n] = av
-		if nv != "" {
-			c.parsedFlags[n] = nv
+	for _, g := range cmd.groups {
+		err := g.validate(func(n string) bool {
+			return c.isFlagPassed(cmd, n)
+		})
+		if err != nil {
+			return err
 		}
 	}
 
@@ -215,62 +661,193 @@ func (c *CLI) parseFlags(cmd *CLICmd) int {
 		}
 
 		f := cmd.GetArg(n)
+		v, err := f.readFileValue(true, v)
+		if err != nil {
+			return err
+		}
+		v = c.promptIfMissing(f, true, v, "")
 
-		err := f.ValidateValue(true, v, "")
+		// items read from stdin are validated by ArgIterator
+		if v == "-" && f.IsAllowStdin() {
+			c.parsedArgs[n] = v
+			continue
+		}
+
+		err = f.ValidateValue(true, v, "")
 		if err != nil {
-			fmt.Fprintf(c.stderr, "ERROR: "+err.Error()+"\n")
-			cmd.PrintHelp(c)
-			return 1
+			return err
 		}
 
 		c.parsedArgs[n] = v
 	}
+	c.passthrough = getPassthrough(fargs, args, len(as))
 
 	postv := cmd.GetPostValidation()
-	if postv != nil {
+	if postv != nil && !c.dryRun {
 		err := postv(c)
 		if err != nil {
-			fmt.Fprintf(c.stderr, "ERROR: "+err.Error()+"\n")
-			cmd.PrintHelp(c)
-			return 1
+			return err
 		}
 	}
-	return 0
+	return nil
 }
 
 // SetStdin sets stdin
 func (c *CLI) SetStdin(stdin *os.File) {
 	c.stdin = stdin
+	c.stdinReader = nil
 }
 
 // Run parses the arguments, validates them and executes command handler. In
 // case of invalid arguments, error is printed to stderr and 1 is returned.
-// Return value behaves like exit code.
+// Return value behaves like exit code. Global flags can be passed before and
+// after the command name.
 func (c *CLI) Run(stdout *os.File, stderr *os.File) int {
-	c.stdout = stdout
-	c.stderr = stderr
-	// display help
-	if len(os.Args[1:]) < 1 || (len(os.Args[1:]) == 1 && (os.Args[1] == "-h" || os.Args[1] == "--help")) {
+	return c.RunArgs(os.Args[1:], stdout, stderr)
+}
+
+// RunArgs works like Run but parses args instead of os.Args. args should not
+// contain the program name. Parsed values are kept in a copy of CLI that is
+// passed to handlers and hooks, so CLI can be run many times, also
+// concurrently. Commands and flags should not be added after the first run.
+func (c *CLI) RunArgs(args []string, stdout *os.File, stderr *os.File) int {
+	c.warnInvalid(stderr)
+	return c.run(args, stdout, stderr)
+}
+
+// warnInvalid logs a warning to stderr when definition is invalid.
+func (c *CLI) warnInvalid(stderr *os.File) {
+	if err := c.Validate(); err != nil {
+		c.newInvocation(nil, stderr).newLogger().Warn("Invalid definition", "error", err.Error())
+	}
+}
+
+// run parses args and runs the command on a copy of CLI. Definition is not
+// validated. Finally hooks are called with the exit code regardless of the
+// outcome.
+func (c *CLI) run(args []string, stdout *os.File, stderr *os.File) int {
+	c = c.newInvocation(stdout, stderr)
+	exitCode := c.runResolved(args)
+	c.runFinally(exitCode)
+	return exitCode
+}
+
+// runResolved resolves args and does what they ask for, ie. prints help or
+// version or runs the command, and returns exit code.
+func (c *CLI) runResolved(args []string) int {
+	r, err := c.resolveArgs(args)
+	if err != nil {
+		c.printError(err)
+		if r.action == actionHelp {
+			c.PrintHelp()
+		}
+		return c.GetExitCode(err)
+	}
+	switch r.action {
+	case actionHelp:
 		c.PrintHelp()
 		return 0
+	case actionVersion:
+		return c.runVersion(r.versionFormat)
+	case actionHelpCmd:
+		return c.runHelp(r.args)
 	}
-	for _, n := range c.GetSortedCmds() {
-		if n == os.Args[1] {
-			// display command help
-			if len(os.Args[1:]) == 2 && (os.Args[2] == "-h" || os.Args[2] == "--help") {
-				c.GetCmd(n).PrintHelp(c)
-				return 0
-			}
-			exitCode := c.parseFlags(c.GetCmd(n))
-			if exitCode > 0 {
-				return exitCode
-			}
-			return c.GetCmd(n).Run(c)
+
+	cmd := r.cmd
+	if cmd.IsDeprecated() {
+		cmd.PrintDeprecationWarning(c)
+	}
+	cmd = cmd.clone()
+	c.cmd = cmd
+	err = c.parseFlags(cmd, r.args, r.gptrs)
+	if err == flag.ErrHelp {
+		r.cmd.PrintHelp(c)
+		return 0
+	}
+	if vr, ok := err.(*versionRequest); ok {
+		return c.runVersion(vr.format)
+	}
+	if err != nil {
+		c.printError(err)
+		cmd.PrintHelp(c)
+		return c.GetExitCode(err)
+	}
+	c.logger = c.newLogger()
+	c.logParsed(cmd)
+	return c.execute(cmd)
+}
+
+// Actions that are taken by Run depending on args.
+const (
+	actionNone = iota
+	actionHelp
+	actionVersion
+	actionHelpCmd
+	actionRun
+)
+
+// resolvedArgs is what args passed to Run resolve to: action, command with
+// its args and pointers to values of global flags passed before command
+// name.
+type resolvedArgs struct {
+	action int
+	cmd    *CLICmd
+	args   []string
+	gptrs  [2]map[string]interface{}
+
+	versionFormat string
+}
+
+// resolveArgs expands response files, parses global flags that are before
+// the command name and finds what should be done with args. Flags of the
+// command are not parsed. When error is returned, action is actionHelp if
+// help should be printed after it.
+func (c *CLI) resolveArgs(args []string) (*resolvedArgs, error) {
+	r := &resolvedArgs{}
+	if c.responseFiles {
+		var err error
+		args, err = c.expandResponseFiles(args, "command line", 0)
+		if err != nil {
+			return r, err
 		}
 	}
-	// command not found
-	c.PrintInvalidCmd(os.Args[1])
-	return 1
+	r.action = actionHelp
+	if len(args) < 1 {
+		return r, nil
+	}
+
+	// global flags before command name
+	fset := newFlagSet()
+	r.gptrs[0], r.gptrs[1] = defineFlags(fset, append(c.getGlobalFlagList(), getSecretFileFlags(c.getGlobalFlagList())...))
+	err := fset.Parse(args)
+	if err == flag.ErrHelp {
+		return r, nil
+	}
+	if err != nil {
+		return r, newFlagSetError(err, fset, args, c.getGlobalFlagList())
+	}
+	args = fset.Args()
+	if vr := c.getVersionRequest(nil, nil, nil, r.gptrs); vr != nil {
+		r.action = actionVersion
+		r.versionFormat = vr.format
+		return r, nil
+	}
+	if len(args) < 1 {
+		return r, nil
+	}
+	if args[0] == HelpCmd && c.hasHelpCmd() {
+		r.action = actionHelpCmd
+		r.args = args[1:]
+		return r, c.checkHelpNames(r.args)
+	}
+
+	r.cmd = c.FindCmd(args[0])
+	if r.cmd == nil {
+		return r, c.newUnknownCommandError(args[0])
+	}
+	r.args = args[1:]
+	r.action = actionRun
+	return r, nil
 }
 
 // Flag returns value of flag.
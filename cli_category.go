@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// category is a section of commands in help.
+type category struct {
+	name  string
+	title string
+}
+
+// SetCategory sets category of the command. Commands of the same category are
+// printed in a separate section of help.
+func (c *CLICmd) SetCategory(n string) {
+	c.category = n
+}
+
+// GetCategory returns category of the command.
+func (c *CLICmd) GetCategory() string {
+	return c.category
+}
+
+// AddCategory adds category n with title t that is printed in help above its
+// commands. Categories are printed in the order they were added, after
+// commands without a category. Categories that are set on commands but not
+// added are printed last, sorted by name that is used as a title.
+func (c *CLI) AddCategory(n string, t string) {
+	for _, ct := range c.categories {
+		if ct.name == n {
+			c.defErrs = append(c.defErrs, &DefinitionError{Reason: "category " + n + " is added more than once"})
+			return
+		}
+	}
+	c.categories = append(c.categories, &category{name: n, title: t})
+}
+
+// SetSortCmds sets whether commands in help are sorted by name, which is the
+// default, or printed in the order they were added.
+func (c *CLI) SetSortCmds(b bool) {
+	c.unsortedCmds = !b
+}
+
+// getHelpCmds returns names of commands in the order they are printed in
+// help.
+func (c *CLI) getHelpCmds() []string {
+	if c.unsortedCmds {
+		return c.cmdsOrder
+	}
+	return c.GetSortedCmds()
+}
+
+// getHelpCategories returns categories in the order they are printed in help,
+// starting with an empty one for commands without a category.
+func (c *CLI) getHelpCategories() []*category {
+	cts := []*category{{title: "Commands"}}
+	known := map[string]bool{"": true}
+	for _, ct := range c.categories {
+		cts = append(cts, ct)
+		known[ct.name] = true
+	}
+	var other []string
+	for _, n := range c.GetSortedCmds() {
+		ctn := c.GetCmd(n).GetCategory()
+		if !known[ctn] {
+			known[ctn] = true
+			other = append(other, ctn)
+		}
+	}
+	sort.Strings(other)
+	for _, ctn := range other {
+		cts = append(cts, &category{name: ctn, title: ctn})
+	}
+	return cts
+}
+
+// printHelpCmds prints commands that are not hidden to w, in sections of
+// their categories. Built-in help command is printed with commands without
+// a category.
+func (c *CLI) printHelpCmds(w *tabwriter.Writer) {
+	printed := false
+	for i, ct := range c.getHelpCategories() {
+		s := ""
+		for _, n := range c.getHelpCmds() {
+			cmd := c.GetCmd(n)
+			if cmd.IsHidden() || cmd.GetCategory() != ct.name {
+				continue
+			}
+			ns := strings.Join(append([]string{n}, cmd.GetAliases()...), ", ")
+			d := cmd.GetDesc()
+			if cmd.IsDeprecated() {
+				d += " (deprecated)"
+			}
+			s += "  " + ns + "\t" + d + "\n"
+		}
+		if i == 0 && c.hasHelpCmd() {
+			s += "  " + HelpCmd + "\tPrints help for a command or topic\n"
+		}
+		if s == "" {
+			continue
+		}
+		if printed {
+			fmt.Fprintf(w, "\n")
+		}
+		fmt.Fprintln(w, ct.title+":")
+		fmt.Fprint(w, s)
+		w.Flush()
+		printed = true
+	}
+}
@@ -1,8 +1,8 @@
 package cli
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"os"
 	"path"
 	"reflect"
@@ -10,17 +10,34 @@ import (
 	"text/tabwriter"
 )
 
+// HandlerFunc is a command handler that returns an error instead of an exit
+// code. Context passed to it is cancelled when SIGINT, SIGTERM or SIGHUP is
+// received.
+type HandlerFunc func(ctx context.Context, c *CLI) error
+
 // CLICmd represent a command which has a name (used in args when calling app),
-// description, a handler and flags attached to it.
+// description, a handler and flags attached to it. Command can have aliases,
+// be hidden from help or marked as deprecated.
 type CLICmd struct {
 	name           string
 	desc           string
+	aliases        []string
+	hidden         bool
+	deprecated     bool
+	replacedBy     string
+	category       string
 	flags          map[string]*CLIFlag
 	args           map[string]*CLIFlag
 	argsOrder      []string
 	argsIdx        int
 	handler        func(c *CLI) int
+	handlerE       HandlerFunc
 	postValidation func(*CLI) error
+	hooks          hooks
+	groups         []*flagGroup
+	rules          []*requireRule
+	examples       []*example
+	defErrs        []error
 }
 
 // GetName returns CLICmd name.
@@ -33,6 +50,67 @@ func (c *CLICmd) GetDesc() string {
 	return c.desc
 }
 
+// AddAlias adds an alternative name a that can be used to call the command.
+func (c *CLICmd) AddAlias(a string) {
+	c.aliases = append(c.aliases, a)
+}
+
+// GetAliases returns CLICmd aliases.
+func (c *CLICmd) GetAliases() []string {
+	return c.aliases
+}
+
+// HasName returns true when n is command name or one of its aliases.
+func (c *CLICmd) HasName(n string) bool {
+	if c.name == n {
+		return true
+	}
+	for _, a := range c.aliases {
+		if a == n {
+			return true
+		}
+	}
+	return false
+}
+
+// SetHidden sets command to be hidden from help and suggestions.
+func (c *CLICmd) SetHidden(h bool) {
+	c.hidden = h
+}
+
+// IsHidden returns true when command is hidden.
+func (c *CLICmd) IsHidden() bool {
+	return c.hidden
+}
+
+// SetDeprecated marks command as deprecated. r is name of a command that
+// should be used instead and it can be empty. Deprecated command still runs
+// but a warning is printed to stderr.
+func (c *CLICmd) SetDeprecated(r string) {
+	c.deprecated = true
+	c.replacedBy = r
+}
+
+// IsDeprecated returns true when command is deprecated.
+func (c *CLICmd) IsDeprecated() bool {
+	return c.deprecated
+}
+
+// GetReplacedBy returns name of the command that replaces deprecated one.
+func (c *CLICmd) GetReplacedBy() string {
+	return c.replacedBy
+}
+
+// PrintDeprecationWarning prints a warning about command being deprecated to
+// stderr file.
+func (c *CLICmd) PrintDeprecationWarning(cli *CLI) {
+	s := "WARNING: Command " + c.GetName() + " is deprecated"
+	if c.replacedBy != "" {
+		s += ", use " + c.replacedBy + " instead"
+	}
+	fmt.Fprintln(cli.GetStderr(), s)
+}
+
 // GetSortedArgs returns arguments list of arg names sorted how they were added
 // but required ones are first.
 func (c *CLICmd) GetSortedArgs() []string {
@@ -76,8 +154,8 @@ func (c *CLICmd) getArgsHelpLine() string {
 
 // PrintHelp prints command usage information to stdout file.
 func (c *CLICmd) PrintHelp(cli *CLI) {
-	fmt.Fprintf(cli.GetStdout(), "\nUsage:  "+path.Base(os.Args[0])+" "+c.GetName()+" [FLAGS]"+c.getArgsHelpLine()+"\n\n")
-	fmt.Fprintf(cli.GetStdout(), c.GetDesc()+"\n")
+	fmt.Fprint(cli.GetStdout(), "\nUsage:  "+path.Base(os.Args[0])+" "+c.GetName()+" [FLAGS]"+c.getArgsHelpLine()+"\n\n")
+	fmt.Fprint(cli.GetStdout(), c.GetDesc()+"\n")
 
 	w := new(tabwriter.Writer)
 	w.Init(cli.GetStdout(), 8, 8, 0, '\t', 0)
@@ -86,6 +164,9 @@ func (c *CLICmd) PrintHelp(cli *CLI) {
 	i := 1
 	for _, n := range c.GetSortedFlags() {
 		flag := c.GetFlag(n)
+		if flag.IsHidden() || flag.IsDeprecated() {
+			continue
+		}
 		if flag.IsRequired() {
 			i = 0
 		} else {
@@ -96,14 +177,23 @@ func (c *CLICmd) PrintHelp(cli *CLI) {
 
 	if s[0] != "" {
 		fmt.Fprintf(w, "\nRequired flags: \n")
-		fmt.Fprintf(w, s[0])
+		fmt.Fprint(w, s[0])
 		w.Flush()
 	}
 	if s[1] != "" {
 		fmt.Fprintf(w, "\nOptional flags: \n")
-		fmt.Fprintf(w, s[1])
+		fmt.Fprint(w, s[1])
+		w.Flush()
+	}
+	if len(c.groups) > 0 {
+		fmt.Fprintf(w, "\nFlag rules: \n")
+		fmt.Fprint(w, c.getGroupsHelp())
 		w.Flush()
 	}
+	if len(c.examples) > 0 {
+		fmt.Fprintf(cli.GetStdout(), "\nExamples:\n")
+		fmt.Fprint(cli.GetStdout(), c.getExamplesHelp())
+	}
 
 }
 
@@ -113,12 +203,21 @@ func (c *CLICmd) AttachFlag(flag *CLIFlag) {
 	if c.flags == nil {
 		c.flags = make(map[string]*CLIFlag)
 	}
+	if _, ok := c.flags[n]; ok {
+		c.defErrs = append(c.defErrs, &DefinitionError{Flag: "flag --" + n, Reason: "flag is added more than once"})
+	}
 	c.flags[n] = flag
 }
 
-// AttachArg attaches instance of CLIFlag to CLICmd but as an argument.
+// AttachArg attaches instance of CLIFlag to CLICmd but as an argument. Only 10
+// arguments are allowed and the ones above the limit are not attached and
+// reported by CLI.Validate.
 func (c *CLICmd) AttachArg(flag *CLIFlag) {
 	n := flag.GetName()
+	if c.argsIdx > 9 {
+		c.defErrs = append(c.defErrs, &DefinitionError{Flag: "argument " + n, Reason: "only 10 arguments are allowed"})
+		return
+	}
 	if c.args == nil {
 		c.args = make(map[string]*CLIFlag)
 	}
@@ -139,9 +238,6 @@ func (c *CLICmd) AddFlag(n string, a string, hv string, d string, nf int32, fn f
 
 // AddArg adds an argument to a command.
 func (c *CLICmd) AddArg(n string, hv string, d string, nf int32) {
-	if c.argsIdx > 9 {
-		log.Fatal("Only 10 arguments are allowed")
-	}
 	arg := NewCLIFlag(n, "", hv, d, nf, nil)
 	c.AttachArg(arg)
 }
@@ -178,6 +274,15 @@ func (c *CLICmd) GetSortedFlags() []string {
 	return sfs
 }
 
+// getFlagList returns flags sorted by name.
+func (c *CLICmd) getFlagList() []*CLIFlag {
+	var fs []*CLIFlag
+	for _, n := range c.GetSortedFlags() {
+		fs = append(fs, c.flags[n])
+	}
+	return fs
+}
+
 // GetFlags returns list of flag names.
 func (c *CLICmd) GetFlags() []reflect.Value {
 	return reflect.ValueOf(c.flags).MapKeys()
@@ -185,7 +290,15 @@ func (c *CLICmd) GetFlags() []reflect.Value {
 
 // Run calls command handler.
 func (c *CLICmd) Run(cli *CLI) int {
-	return c.handler(cli)
+	return c.RunContext(context.Background(), cli)
+}
+
+// RunContext calls command handler along with hooks and middlewares attached
+// to the command and CLI. Context is passed to HandlerFunc and error returned
+// by it is printed to CLI stderr and converted to exit code. Error caused by
+// cancelling the context is not printed.
+func (c *CLICmd) RunContext(ctx context.Context, cli *CLI) int {
+	return cli.getErrorExitCode(ctx, c.runChain(ctx, cli))
 }
 
 // NewCLICmd creates CLICmd instance with name n, description d and handler f
@@ -194,3 +307,10 @@ func NewCLICmd(n string, d string, f func(cli *CLI) int) *CLICmd {
 	c := &CLICmd{name: n, desc: d, handler: f}
 	return c
 }
+
+// NewCLICmdE creates CLICmd instance with name n, description d and handler f
+// that returns an error and returns it.
+func NewCLICmdE(n string, d string, f HandlerFunc) *CLICmd {
+	c := &CLICmd{name: n, desc: d, handlerE: f}
+	return c
+}
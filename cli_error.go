@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrorKind is a class of an error. It is used to map errors to exit codes.
+type ErrorKind int
+
+const (
+	// KindMissingFlag is a class of errors about missing flags and arguments.
+	KindMissingFlag ErrorKind = iota + 1
+	// KindInvalidValue is a class of errors about invalid values.
+	KindInvalidValue
+	// KindPathNotFound is a class of errors about non-existing paths.
+	KindPathNotFound
+	// KindConflictingFlags is a class of errors about flags that cannot be
+	// passed together.
+	KindConflictingFlags
+	// KindUnknownCommand is a class of errors about invalid commands.
+	KindUnknownCommand
+	// KindUnknownFlag is a class of errors about flags that are not defined.
+	KindUnknownFlag
+)
+
+// Exit codes from sysexits.h that can be mapped to error kinds with
+// CLI.SetExitCode.
+const (
+	// ExitUsage means the command was used incorrectly.
+	ExitUsage = 64
+	// ExitDataErr means the input data was incorrect.
+	ExitDataErr = 65
+	// ExitNoInput means an input file did not exist or was not readable.
+	ExitNoInput = 66
+)
+
+// kindError is implemented by all errors returned by the package.
+type kindError interface {
+	error
+	Kind() ErrorKind
+}
+
+// GetErrorKind returns kind of err or 0 when err is not one of the package
+// errors.
+func GetErrorKind(err error) ErrorKind {
+	var ke kindError
+	if errors.As(err, &ke) {
+		return ke.Kind()
+	}
+	return 0
+}
+
+// ExitError can be returned by a command handler to exit with a specific
+// code. Err is printed to stderr unless it is nil.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return "exit code " + strconv.Itoa(e.Code)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+func flagLabel(isArg bool) string {
+	if isArg {
+		return "Argument"
+	}
+	return "Flag"
+}
+
+// MissingFlagError is returned when required flag or argument is missing.
+type MissingFlagError struct {
+	Flag  string
+	IsArg bool
+}
+
+func (e *MissingFlagError) Error() string {
+	return flagLabel(e.IsArg) + " " + e.Flag + " is missing"
+}
+
+// Kind returns KindMissingFlag.
+func (e *MissingFlagError) Kind() ErrorKind {
+	return KindMissingFlag
+}
+
+// FlagGroupError is returned when flags that have to be passed together are
+// not. Flags contains names of all flags in the group, prefixed with --.
+type FlagGroupError struct {
+	Flags  []string
+	Reason string
+}
+
+func (e *FlagGroupError) Error() string {
+	return e.Reason
+}
+
+// Kind returns KindMissingFlag.
+func (e *FlagGroupError) Kind() ErrorKind {
+	return KindMissingFlag
+}
+
+// InvalidValueError is returned when flag or argument value does not match
+// its type. Reason describes what was expected.
+type InvalidValueError struct {
+	Flag   string
+	IsArg  bool
+	Value  string
+	Reason string
+}
+
+func (e *InvalidValueError) Error() string {
+	return flagLabel(e.IsArg) + " " + e.Flag + " has invalid value"
+}
+
+// Kind returns KindInvalidValue.
+func (e *InvalidValueError) Kind() ErrorKind {
+	return KindInvalidValue
+}
+
+// PathNotFoundError is returned when flag or argument points to a file or
+// a directory that does not exist.
+type PathNotFoundError struct {
+	Flag  string
+	IsArg bool
+	Path  string
+	IsDir bool
+}
+
+func (e *PathNotFoundError) Error() string {
+	s := "File "
+	if e.IsDir {
+		s = "Directory "
+	}
+	return s + e.Path + " from " + e.Flag + " does not exist"
+}
+
+// Kind returns KindPathNotFound.
+func (e *PathNotFoundError) Kind() ErrorKind {
+	return KindPathNotFound
+}
+
+// InvalidPathError is returned when path exists but it is not of a required
+// type, eg. it is a directory but a regular file was expected.
+type InvalidPathError struct {
+	Flag   string
+	IsArg  bool
+	Path   string
+	Reason string
+}
+
+func (e *InvalidPathError) Error() string {
+	return "Path " + e.Path + " from " + e.Flag + " " + e.Reason
+}
+
+// Kind returns KindInvalidValue.
+func (e *InvalidPathError) Kind() ErrorKind {
+	return KindInvalidValue
+}
+
+// ConflictingFlagsError is returned when flags that cannot be used together
+// are passed.
+type ConflictingFlagsError struct {
+	Flags []string
+}
+
+func (e *ConflictingFlagsError) Error() string {
+	if len(e.Flags) == 2 {
+		return "Both " + e.Flags[0] + " and " + e.Flags[1] + " passed"
+	}
+	return "Flags " + strings.Join(e.Flags, ", ") + " cannot be passed together"
+}
+
+// Kind returns KindConflictingFlags.
+func (e *ConflictingFlagsError) Kind() ErrorKind {
+	return KindConflictingFlags
+}
+
+// UnknownCommandError is returned when command does not exist. Suggestion
+// contains name of a similar command if there is any.
+type UnknownCommandError struct {
+	Command    string
+	Suggestion string
+}
+
+func (e *UnknownCommandError) Error() string {
+	return "Invalid command: " + e.Command
+}
+
+// Kind returns KindUnknownCommand.
+func (e *UnknownCommandError) Kind() ErrorKind {
+	return KindUnknownCommand
+}
+
+// UnknownFlagError is returned when flag is not defined. Suggestion contains
+// a similar flag if there is any.
+type UnknownFlagError struct {
+	Flag       string
+	Suggestion string
+}
+
+func (e *UnknownFlagError) Error() string {
+	return "Unknown flag " + e.Flag
+}
+
+// Kind returns KindUnknownFlag.
+func (e *UnknownFlagError) Kind() ErrorKind {
+	return KindUnknownFlag
+}
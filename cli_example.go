@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path"
+)
+
+// example is a usage example of a command.
+type example struct {
+	line string
+	desc string
+}
+
+// AddExample adds usage example to the command. l is a command line without
+// the program name, eg. "start -u bob", and d is its description that can be
+// empty. Examples are printed in command help and can be checked with
+// CLI.ValidateExamples.
+func (c *CLICmd) AddExample(l string, d string) {
+	c.examples = append(c.examples, &example{line: l, desc: d})
+}
+
+// getExamplesHelp returns examples formatted for help.
+func (c *CLICmd) getExamplesHelp() string {
+	s := ""
+	for i, e := range c.examples {
+		if i > 0 {
+			s += "\n"
+		}
+		if e.desc != "" {
+			s += "  # " + e.desc + "\n"
+		}
+		s += "  " + path.Base(os.Args[0]) + " " + e.line + "\n"
+	}
+	return s
+}
+
+// ExampleError is returned by ValidateExamples when example of a command is
+// not a valid command line.
+type ExampleError struct {
+	Command string
+	Example string
+	Err     error
+}
+
+func (e *ExampleError) Error() string {
+	return "command " + e.Command + ": example '" + e.Example + "' is invalid: " + e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *ExampleError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateExamples parses examples of all commands, without running
+// handlers, and returns an error for each example that is not a valid
+// command line for its command. It is meant to be called in tests.
+// Examples are parsed like with Parse, ie. without prompts, post validation
+// and with empty stdin.
+func (c *CLI) ValidateExamples() error {
+	null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer null.Close()
+
+	var errs []error
+	for _, n := range c.GetSortedCmds() {
+		cmd := c.GetCmd(n)
+		for _, e := range cmd.examples {
+			err := c.validateExample(cmd, e.line, null)
+			if err != nil {
+				errs = append(errs, &ExampleError{Command: n, Example: e.line, Err: err})
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateExample parses command line l on a copy of CLI that has all files
+// set to null and returns an error when l does not run cmd or it is invalid.
+func (c *CLI) validateExample(cmd *CLICmd, l string, null *os.File) error {
+	args, err := Split(l)
+	if err != nil {
+		return err
+	}
+	dc := c.newDryRun(null)
+	r, err := dc.resolveArgs(args)
+	if err != nil {
+		return err
+	}
+	if r.cmd != cmd {
+		return errors.New("it does not run command " + cmd.GetName())
+	}
+	if r.action != actionRun {
+		return nil
+	}
+	err = dc.parseFlags(r.cmd.clone(), r.args, r.gptrs)
+	if _, ok := err.(*versionRequest); ok || err == flag.ErrHelp {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxResponseFileDepth is how deep response files can be nested.
+const MaxResponseFileDepth = 10
+
+// SetResponseFiles enables expanding arguments starting with @ to contents
+// of the file they point to, eg. "app @args.txt". File is split into
+// arguments with Split. Arguments starting with @@ are passed with a single
+// @. Values of AllowFileValue flags, eg. "--body @payload.json", are not
+// expanded, but argument that allows file value has to be passed as @@path
+// when response files are enabled.
+func (c *CLI) SetResponseFiles(b bool) {
+	c.responseFiles = b
+}
+
+// expandResponseFiles replaces arguments starting with @ with contents of the
+// file. src is where args come from and it is used in error messages.
+func (c *CLI) expandResponseFiles(args []string, src string, depth int) ([]string, error) {
+	var ex []string
+	for i, a := range args {
+		if a == "--" {
+			return append(ex, args[i:]...), nil
+		}
+		if i > 0 && c.isFileValueFlagArg(args[i-1]) {
+			ex = append(ex, a)
+			continue
+		}
+		if strings.HasPrefix(a, "@@") {
+			ex = append(ex, a[1:])
+			continue
+		}
+		if !strings.HasPrefix(a, "@") || len(a) == 1 {
+			ex = append(ex, a)
+			continue
+		}
+		if depth >= MaxResponseFileDepth {
+			return nil, errors.New("Response file " + a[1:] + " is nested too deeply")
+		}
+		b, err := ioutil.ReadFile(a[1:])
+		if os.IsNotExist(err) {
+			return nil, &PathNotFoundError{Flag: src, Path: a[1:]}
+		}
+		if err != nil {
+			return nil, err
+		}
+		words, err := Split(string(b))
+		if err != nil {
+			return nil, errors.New("Response file " + a[1:] + " is invalid: " + err.Error())
+		}
+		words, err = c.expandResponseFiles(words, filepath.Base(a[1:]), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		ex = append(ex, words...)
+	}
+	return ex, nil
+}
+
+// isFileValueFlagArg returns true when a is -ALIAS or --NAME of a flag that
+// takes a value and allows reading it from a file. Flags of all commands are
+// checked as the command may not be known while response files are expanded.
+func (c *CLI) isFileValueFlagArg(a string) bool {
+	if !strings.HasPrefix(a, "-") || strings.Contains(a, "=") {
+		return false
+	}
+	fs := c.getGlobalFlagList()
+	for _, n := range c.GetSortedCmds() {
+		fs = append(fs, c.GetCmd(n).getFlagList()...)
+	}
+	for _, f := range fs {
+		if !f.IsAllowFileValue() || f.nflags&(TypeBool|TypeCount) > 0 {
+			continue
+		}
+		if a == "--"+f.GetName() || a == "-"+f.GetName() || (f.GetAlias() != "" && (a == "-"+f.GetAlias() || a == "--"+f.GetAlias())) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAllowFileValue returns true when flag value can be read from a file.
+func (c *CLIFlag) IsAllowFileValue() bool {
+	return c.nflags&AllowFileValue > 0
+}
+
+// readFileValue returns contents of the file when v starts with @ and flag
+// allows it. Value starting with @@ is returned with a single @.
+func (c *CLIFlag) readFileValue(isArg bool, v string) (string, error) {
+	if !c.IsAllowFileValue() || !strings.HasPrefix(v, "@") {
+		return v, nil
+	}
+	if strings.HasPrefix(v, "@@") {
+		return v[1:], nil
+	}
+	nlabel := c.GetName()
+	if isArg {
+		nlabel = c.GetHelpValue()
+	}
+	b, err := ioutil.ReadFile(v[1:])
+	if os.IsNotExist(err) {
+		return "", &PathNotFoundError{Flag: nlabel, IsArg: isArg, Path: v[1:]}
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// resolveFileValues reads values of flags from fs that were passed as @path
+// and stores them in pointers so they are validated like any other.
+func resolveFileValues(fs []*CLIFlag, nptrs map[string]interface{}, aptrs map[string]interface{}) error {
+	for _, f := range fs {
+		if !f.IsAllowFileValue() {
+			continue
+		}
+		for _, p := range []interface{}{nptrs[f.GetName()], aptrs[f.GetAlias()]} {
+			sp, ok := p.(*string)
+			if !ok {
+				continue
+			}
+			v, err := f.readFileValue(false, *sp)
+			if err != nil {
+				return err
+			}
+			*sp = v
+		}
+	}
+	return nil
+}
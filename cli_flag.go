@@ -1,9 +1,9 @@
 package cli
 
 import (
-	"errors"
 	"os"
 	"regexp"
+	"strings"
 )
 
 const (
@@ -41,18 +41,39 @@ const (
 	TypeFQDN            = 131072
 	TypePathDir         = 262144
 	TypePathRegularFile = 524288
+	// Hidden hides flag from help and suggestions.
+	Hidden = 1048576
+	// Secret masks flag value in output. Value can be read from a file passed
+	// with --NAME-file flag or from stdin when it is "-".
+	Secret = 2097152
+	// AllowFileValue allows value to be read from a file when it is passed
+	// as @path. Value starting with @@ is passed with a single @.
+	AllowFileValue = 4194304
+	// AllowStdin allows argument to be "-" which means its items are read from
+	// stdin, separated with newlines. Items are read with CLI.ArgItems.
+	AllowStdin = 8388608
+	// StdinSeparatorNUL works with AllowStdin and sets NUL character to be
+	// the item separator, eg. for output of "find -print0".
+	StdinSeparatorNUL = 16777216
+	// TypeCount sets flag to count how many times it was passed, eg. -v -v.
+	// Its value is the number.
+	TypeCount = 33554432
 )
 
 // CLIFlag represends flag. It has a name, alias, description, value that is
 // shown when printing help and configuration which is an integer value. It can
-// be for example Required|TypePathFile|MustExist.
+// be for example Required|TypePathFile|MustExist. Flag can be marked as
+// deprecated and point to a flag that replaces it.
 type CLIFlag struct {
-	name      string
-	alias     string
-	helpValue string
-	desc      string
-	nflags    int32
-	fn        func(*CLICmd)
+	name       string
+	alias      string
+	helpValue  string
+	desc       string
+	nflags     int32
+	fn         func(*CLICmd)
+	deprecated bool
+	replacedBy string
+	prompt     *prompt
 }
 
 // GetName returns flag name.
@@ -94,6 +115,9 @@ func (c *CLIFlag) GetHelpLine() string {
 		s += " -" + c.GetAlias() + ",\t"
 	}
 	s += " --" + c.GetName() + " " + c.GetHelpValue() + " \t" + c.GetDesc() + "\n"
+	if c.IsSecret() {
+		s += "  \t --" + c.getFileFlagName() + " PATH \tRead --" + c.GetName() + " from file, or pass - to read it from stdin\n"
+	}
 	return s
 }
 
@@ -104,7 +128,30 @@ func (c *CLIFlag) IsRequired() bool {
 
 // IsRequireValue returns true when flag requires a value (only bool one returns false).
 func (c *CLIFlag) IsRequireValue() bool {
-	return c.nflags&TypeString > 0 || c.nflags&TypePathFile > 0 || c.nflags&TypeInt > 0 || c.nflags&TypeFloat > 0 || c.nflags&TypeAlphanumeric > 0
+	return c.nflags&TypeString > 0 || c.nflags&TypePathFile > 0 || c.nflags&TypePathDir > 0 || c.nflags&TypePathRegularFile > 0 || c.nflags&TypeInt > 0 || c.nflags&TypeFloat > 0 || c.nflags&TypeAlphanumeric > 0
+}
+
+// IsHidden returns true when flag should not be shown in help.
+func (c *CLIFlag) IsHidden() bool {
+	return c.nflags&Hidden > 0
+}
+
+// SetDeprecated marks flag as deprecated. r is name of a flag that replaces
+// it and it can be empty. Deprecated flag is still accepted but a warning is
+// printed and its value is passed to the replacement flag.
+func (c *CLIFlag) SetDeprecated(r string) {
+	c.deprecated = true
+	c.replacedBy = r
+}
+
+// IsDeprecated returns true when flag is deprecated.
+func (c *CLIFlag) IsDeprecated() bool {
+	return c.deprecated
+}
+
+// GetReplacedBy returns name of the flag that replaces deprecated one.
+func (c *CLIFlag) GetReplacedBy() string {
+	return c.replacedBy
 }
 
 // IsTypeBool returns true when flag is of bool type.
@@ -154,16 +201,23 @@ func (c *CLIFlag) ExecFn(o *CLICmd) {
 	}
 }
 
+// getManySeparator returns separator of values when flag allows many.
+func (c *CLIFlag) getManySeparator() string {
+	if c.nflags&ManySeparatorColon > 0 {
+		return ":"
+	} else if c.nflags&ManySeparatorSemiColon > 0 {
+		return ";"
+	}
+	return ","
+}
+
 // ValidateValue takes value coming from --NAME and -ALIAS and validates it.
+// Returned error is one of MissingFlagError, InvalidValueError,
+// PathNotFoundError, InvalidPathError or ConflictingFlagsError.
 func (c *CLIFlag) ValidateValue(isArg bool, nz string, az string) error {
 	// both alias and name cannot be set
 	if nz != "" && az != "" {
-		return errors.New("Both -" + c.GetAlias() + " and --" + c.GetName() + " passed")
-	}
-
-	label := "Flag"
-	if isArg {
-		label = "Argument"
+		return &ConflictingFlagsError{Flags: []string{"-" + c.GetAlias(), "--" + c.GetName()}}
 	}
 
 	nlabel := c.GetName()
@@ -173,8 +227,8 @@ func (c *CLIFlag) ValidateValue(isArg bool, nz string, az string) error {
 
 	// empty
 	if c.IsRequired() && (nz == "" && az == "") {
-		if c.IsTypeString() || c.IsTypePathFile() || c.IsTypePathDir() || c.IsTypeInt() || c.IsTypeFloat() || c.IsTypeAlphanumeric() {
-			return errors.New(label + " " + nlabel + " is missing")
+		if c.IsTypeString() || c.IsTypePathFile() || c.IsTypePathDir() || c.IsTypePathRegularFile() || c.IsTypeInt() || c.IsTypeFloat() || c.IsTypeAlphanumeric() {
+			return &MissingFlagError{Flag: nlabel, IsArg: isArg}
 		}
 	}
 	// string does not need any additional checks apart from the above one
@@ -189,7 +243,7 @@ func (c *CLIFlag) ValidateValue(isArg bool, nz string, az string) error {
 		// if flag is a file and have to exist
 		if c.IsTypePathFile() {
 			if _, err := os.Stat(v); os.IsNotExist(err) {
-				return errors.New("File " + v + " from " + nlabel + " does not exist")
+				return &PathNotFoundError{Flag: nlabel, IsArg: isArg, Path: c.MaskValue(v)}
 			}
 			return nil
 		}
@@ -197,10 +251,10 @@ func (c *CLIFlag) ValidateValue(isArg bool, nz string, az string) error {
 		if c.IsTypePathRegularFile() {
 			fileInfo, err := os.Stat(v)
 			if os.IsNotExist(err) {
-				return errors.New("File " + v + " from " + nlabel + " does not exist")
+				return &PathNotFoundError{Flag: nlabel, IsArg: isArg, Path: c.MaskValue(v)}
 			}
 			if !fileInfo.Mode().IsRegular() {
-				return errors.New("Path " + v + " from " + nlabel + " is not a regular file")
+				return &InvalidPathError{Flag: nlabel, IsArg: isArg, Path: c.MaskValue(v), Reason: "is not a regular file"}
 			}
 			return nil
 		}
@@ -208,10 +262,10 @@ func (c *CLIFlag) ValidateValue(isArg bool, nz string, az string) error {
 		if c.IsTypePathDir() {
 			fileInfo, err := os.Stat(v)
 			if os.IsNotExist(err) {
-				return errors.New("Directory " + v + " from " + nlabel + " does not exist")
+				return &PathNotFoundError{Flag: nlabel, IsArg: isArg, Path: c.MaskValue(v), IsDir: true}
 			}
 			if !fileInfo.IsDir() {
-				return errors.New("Path " + v + " from " + nlabel + " is not a directory")
+				return &InvalidPathError{Flag: nlabel, IsArg: isArg, Path: c.MaskValue(v), Reason: "is not a directory"}
 			}
 			return nil
 		}
@@ -243,26 +297,53 @@ func (c *CLIFlag) ValidateValue(isArg bool, nz string, az string) error {
 		}
 		// create the final regexp depending on if single or many values are allowed
 		if c.nflags&AllowMany > 0 {
-			var d string
-			if c.nflags&ManySeparatorColon > 0 {
-				d = ":"
-			} else if c.nflags&ManySeparatorSemiColon > 0 {
-				d = ";"
-			} else {
-				d = ","
-			}
+			d := c.getManySeparator()
 			reValue = "^" + reType + "(" + d + reType + ")*$"
 		} else {
 			reValue = "^" + reType + "$"
 		}
 		m, err := regexp.MatchString(reValue, v)
 		if err != nil || !m {
-			return errors.New(label + " " + nlabel + " has invalid value")
+			return &InvalidValueError{Flag: nlabel, IsArg: isArg, Value: c.MaskValue(v), Reason: c.getTypeReason()}
 		}
 	}
 	return nil
 }
 
+// getTypeReason returns description of values that int, float and
+// alphanumeric flag accepts, which is used in InvalidValueError.
+func (c *CLIFlag) getTypeReason() string {
+	many := c.nflags&AllowMany > 0
+	sep := " separated with '" + c.getManySeparator() + "'"
+	if c.IsTypeInt() {
+		if many {
+			return "must be integers" + sep
+		}
+		return "must be an integer"
+	}
+	if c.IsTypeFloat() {
+		if many {
+			return "must be decimal numbers" + sep
+		}
+		return "must be a decimal number, eg. 1.5"
+	}
+	chars := []string{"letters", "digits"}
+	if c.nflags&AllowUnderscore > 0 {
+		chars = append(chars, "underscores")
+	}
+	if c.nflags&AllowDots > 0 {
+		chars = append(chars, "dots")
+	}
+	if c.nflags&AllowHyphen > 0 {
+		chars = append(chars, "hyphens")
+	}
+	s := strings.Join(chars[:len(chars)-1], ", ") + " and " + chars[len(chars)-1]
+	if many {
+		return "must be values" + sep + " that contain only " + s
+	}
+	return "must contain only " + s
+}
+
 // NewCLIFlag creates instance of CLIFlag and returns it.
 func NewCLIFlag(n string, a string, hv string, d string, nf int32, fn func(*CLICmd)) *CLIFlag {
 	f := &CLIFlag{name: n, alias: a, helpValue: hv, desc: d, nflags: nf, fn: fn}
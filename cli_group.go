@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"strings"
+)
+
+const (
+	groupMutuallyExclusive = iota + 1
+	groupAtLeastOne
+	groupExactlyOne
+	groupAllOrNone
+	groupRequires
+	groupConflicts
+)
+
+// flagGroup is a rule about flags that are passed together. For requires and
+// conflicts rules, flag is the one the rule applies to.
+type flagGroup struct {
+	kind  int
+	flag  string
+	flags []string
+}
+
+// AddMutuallyExclusive adds a rule that at most one of flags fs can be passed.
+func (c *CLICmd) AddMutuallyExclusive(fs ...string) {
+	c.groups = append(c.groups, &flagGroup{kind: groupMutuallyExclusive, flags: fs})
+}
+
+// AddAtLeastOne adds a rule that at least one of flags fs has to be passed.
+func (c *CLICmd) AddAtLeastOne(fs ...string) {
+	c.groups = append(c.groups, &flagGroup{kind: groupAtLeastOne, flags: fs})
+}
+
+// AddExactlyOne adds a rule that exactly one of flags fs has to be passed.
+func (c *CLICmd) AddExactlyOne(fs ...string) {
+	c.groups = append(c.groups, &flagGroup{kind: groupExactlyOne, flags: fs})
+}
+
+// AddAllOrNone adds a rule that either all flags fs or none of them have to
+// be passed.
+func (c *CLICmd) AddAllOrNone(fs ...string) {
+	c.groups = append(c.groups, &flagGroup{kind: groupAllOrNone, flags: fs})
+}
+
+// AddRequires adds a rule that when flag f is passed, flags fs have to be
+// passed as well.
+func (c *CLICmd) AddRequires(f string, fs ...string) {
+	c.groups = append(c.groups, &flagGroup{kind: groupRequires, flag: f, flags: fs})
+}
+
+// AddConflicts adds a rule that when flag f is passed, none of flags fs can
+// be passed.
+func (c *CLICmd) AddConflicts(f string, fs ...string) {
+	c.groups = append(c.groups, &flagGroup{kind: groupConflicts, flag: f, flags: fs})
+}
+
+// getGroupsHelp returns description of flag rules that is used when printing
+// help.
+func (c *CLICmd) getGroupsHelp() string {
+	s := ""
+	for _, g := range c.groups {
+		s += "  " + g.describe() + "\n"
+	}
+	return s
+}
+
+func dashed(fs []string) []string {
+	d := make([]string, len(fs))
+	for i, f := range fs {
+		d[i] = "--" + f
+	}
+	return d
+}
+
+// describe returns human-readable description of the rule.
+func (g *flagGroup) describe() string {
+	fs := strings.Join(dashed(g.flags), ", ")
+	switch g.kind {
+	case groupMutuallyExclusive:
+		return "At most one of " + fs + " can be passed"
+	case groupAtLeastOne:
+		return "At least one of " + fs + " is required"
+	case groupExactlyOne:
+		return "Exactly one of " + fs + " is required"
+	case groupAllOrNone:
+		return "Flags " + fs + " must be passed together"
+	case groupRequires:
+		return "Flag --" + g.flag + " requires " + fs
+	case groupConflicts:
+		return "Flag --" + g.flag + " conflicts with " + fs
+	}
+	return ""
+}
+
+// validate checks the rule against flags passed, where passed returns true
+// for flag that was passed.
+func (g *flagGroup) validate(passed func(string) bool) error {
+	var set []string
+	var unset []string
+	for _, f := range g.flags {
+		if passed(f) {
+			set = append(set, f)
+		} else {
+			unset = append(unset, f)
+		}
+	}
+	fail := false
+	switch g.kind {
+	case groupMutuallyExclusive:
+		if len(set) > 1 {
+			return &ConflictingFlagsError{Flags: dashed(set)}
+		}
+	case groupAtLeastOne:
+		fail = len(set) == 0
+	case groupExactlyOne:
+		if len(set) > 1 {
+			return &ConflictingFlagsError{Flags: dashed(set)}
+		}
+		fail = len(set) == 0
+	case groupAllOrNone:
+		fail = len(set) > 0 && len(unset) > 0
+	case groupRequires:
+		fail = passed(g.flag) && len(unset) > 0
+	case groupConflicts:
+		if passed(g.flag) && len(set) > 0 {
+			return &ConflictingFlagsError{Flags: dashed(append([]string{g.flag}, set...))}
+		}
+	}
+	if fail {
+		return &FlagGroupError{Flags: dashed(g.flags), Reason: g.describe()}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// HelpCmd is name of the built-in command that prints help for commands and
+// topics. It is not available when a command with the same name is added.
+const HelpCmd = "help"
+
+// helpTopic is a page of help that is not about a command, eg. description
+// of environment variables.
+type helpTopic struct {
+	name string
+	desc string
+	text string
+}
+
+// AddHelpTopic adds a help topic n with short description d, which is listed
+// in help, and text t that is printed with 'help n'.
+func (c *CLI) AddHelpTopic(n string, d string, t string) {
+	if c.helpTopics == nil {
+		c.helpTopics = make(map[string]*helpTopic)
+	}
+	if _, ok := c.helpTopics[n]; ok {
+		c.defErrs = append(c.defErrs, &DefinitionError{Reason: "help topic " + n + " is added more than once"})
+	}
+	c.helpTopics[n] = &helpTopic{name: n, desc: d, text: t}
+}
+
+// GetSortedHelpTopics returns sorted list of help topic names.
+func (c *CLI) GetSortedHelpTopics() []string {
+	ts := reflect.ValueOf(c.helpTopics).MapKeys()
+	sts := make([]string, len(ts))
+	for i, t := range ts {
+		sts[i] = t.String()
+	}
+	sort.Strings(sts)
+	return sts
+}
+
+// hasHelpCmd returns true when built-in help command is available.
+func (c *CLI) hasHelpCmd() bool {
+	return c.FindCmd(HelpCmd) == nil
+}
+
+// printHelpTopics prints list of help topics to w.
+func (c *CLI) printHelpTopics(w *tabwriter.Writer) {
+	if len(c.helpTopics) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nHelp topics:\n")
+	for _, n := range c.GetSortedHelpTopics() {
+		fmt.Fprint(w, "  "+n+"\t"+c.helpTopics[n].desc+"\n")
+	}
+	w.Flush()
+}
+
+// runHelp prints help for each command or topic in ns, or the main help when
+// ns is empty, and returns exit code.
+func (c *CLI) runHelp(ns []string) int {
+	if err := c.checkHelpNames(ns); err != nil {
+		c.printError(err)
+		return c.GetExitCode(err)
+	}
+	if len(ns) == 0 {
+		c.PrintHelp()
+		return 0
+	}
+	for _, n := range ns {
+		if cmd := c.FindCmd(n); cmd != nil {
+			cmd.PrintHelp(c)
+		} else {
+			fmt.Fprintln(c.stdout, c.helpTopics[n].text)
+		}
+	}
+	return 0
+}
+
+// checkHelpNames returns UnknownCommandError when any of ns is neither
+// a command nor a help topic.
+func (c *CLI) checkHelpNames(ns []string) error {
+	for _, n := range ns {
+		if _, ok := c.helpTopics[n]; ok || c.FindCmd(n) != nil {
+			continue
+		}
+		return &UnknownCommandError{Command: n, Suggestion: suggest(n, append(c.getVisibleCmdNames(), c.GetSortedHelpTopics()...))}
+	}
+	return nil
+}
+
+// hasHelpArg returns true when -h or --help is in args that were left after
+// fset parsed args, ie. after the first argument, and before "--". Values of
+// flags that take one are skipped, and so are -h and --help when they are
+// defined in fset.
+func hasHelpArg(fset *flag.FlagSet, args []string) bool {
+	rest := fset.Args()
+	if i := len(args) - len(rest); i > 0 && args[i-1] == "--" {
+		return false
+	}
+	for i := 0; i < len(rest); i++ {
+		a := rest[i]
+		if a == "--" {
+			return false
+		}
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			continue
+		}
+		n := strings.TrimLeft(a, "-")
+		if strings.Contains(n, "=") {
+			continue
+		}
+		f := fset.Lookup(n)
+		if f == nil {
+			if n == "h" || n == "help" {
+				return true
+			}
+			continue
+		}
+		if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !bf.IsBoolFlag() {
+			i++
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"context"
+	"errors"
+)
+
+// Hook is called before or after command handler. Returning an error stops
+// the execution. ExitError can be used to exit with a specific code.
+type Hook func(ctx context.Context, c *CLI) error
+
+// FinallyHook is always called with the exit code when Run finishes, also
+// when args were invalid or command was interrupted by a signal.
+type FinallyHook func(c *CLI, exitCode int)
+
+// Middleware wraps a handler, eg. to measure time it takes to run.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// hooks contains hooks and middlewares attached to CLI or CLICmd.
+type hooks struct {
+	preRun      []Hook
+	postRun     []Hook
+	finally     []FinallyHook
+	middlewares []Middleware
+}
+
+// AddPreRun adds a hook that is called before handler of every command.
+// Hooks attached to CLI are called before the ones attached to CLICmd.
+func (c *CLI) AddPreRun(h Hook) {
+	c.hooks.preRun = append(c.hooks.preRun, h)
+}
+
+// AddPostRun adds a hook that is called after handler of every command
+// succeeds. Hooks attached to CLI are called after the ones attached to
+// CLICmd.
+func (c *CLI) AddPostRun(h Hook) {
+	c.hooks.postRun = append(c.hooks.postRun, h)
+}
+
+// AddFinally adds a hook that is always called when Run finishes, after
+// cleanup hooks. Hooks attached to CLI are called after the ones attached to
+// CLICmd, which are called only when args resolve to the command.
+func (c *CLI) AddFinally(h FinallyHook) {
+	c.hooks.finally = append(c.hooks.finally, h)
+}
+
+// Use adds a middleware that wraps handler of every command. Middlewares
+// attached to CLI wrap the ones attached to CLICmd and the first one added
+// is the outermost.
+func (c *CLI) Use(m Middleware) {
+	c.hooks.middlewares = append(c.hooks.middlewares, m)
+}
+
+// AddPreRun adds a hook that is called before command handler.
+func (c *CLICmd) AddPreRun(h Hook) {
+	c.hooks.preRun = append(c.hooks.preRun, h)
+}
+
+// AddPostRun adds a hook that is called after command handler succeeds.
+func (c *CLICmd) AddPostRun(h Hook) {
+	c.hooks.postRun = append(c.hooks.postRun, h)
+}
+
+// AddFinally adds a hook that is always called when Run finishes after args
+// resolved to the command, also when its flags were invalid.
+func (c *CLICmd) AddFinally(h FinallyHook) {
+	c.hooks.finally = append(c.hooks.finally, h)
+}
+
+// Use adds a middleware that wraps command handler. The first one added is
+// the outermost.
+func (c *CLICmd) Use(m Middleware) {
+	c.hooks.middlewares = append(c.hooks.middlewares, m)
+}
+
+// getHandler returns command handler as HandlerFunc wrapped with middlewares.
+// Non-zero exit code returned by handler is converted to ExitError.
+func (c *CLICmd) getHandler(cli *CLI) HandlerFunc {
+	fn := c.handlerE
+	if fn == nil {
+		fn = func(ctx context.Context, cli *CLI) error {
+			exitCode := c.handler(cli)
+			if exitCode != 0 {
+				return &ExitError{Code: exitCode}
+			}
+			return nil
+		}
+	}
+	for i := len(c.hooks.middlewares) - 1; i >= 0; i-- {
+		fn = c.hooks.middlewares[i](fn)
+	}
+	for i := len(cli.hooks.middlewares) - 1; i >= 0; i-- {
+		fn = cli.hooks.middlewares[i](fn)
+	}
+	return fn
+}
+
+// runChain calls pre-run hooks, handler and post-run hooks, and stops on the
+// first error.
+func (c *CLICmd) runChain(ctx context.Context, cli *CLI) error {
+	for _, h := range append(append([]Hook{}, cli.hooks.preRun...), c.hooks.preRun...) {
+		if err := h(ctx, cli); err != nil {
+			return err
+		}
+	}
+	if err := c.getHandler(cli)(ctx, cli); err != nil {
+		return err
+	}
+	for _, h := range append(append([]Hook{}, c.hooks.postRun...), cli.hooks.postRun...) {
+		if err := h(ctx, cli); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runFinally calls finally hooks of the command that was run, if any, and
+// then the CLI ones.
+func (c *CLI) runFinally(exitCode int) {
+	if c.cmd != nil {
+		for _, h := range c.cmd.hooks.finally {
+			h(c, exitCode)
+		}
+	}
+	for _, h := range c.hooks.finally {
+		h(c, exitCode)
+	}
+}
+
+// getErrorExitCode prints err to CLI stderr and returns exit code for it.
+// ExitError without wrapped error and error caused by cancelling ctx are not
+// printed.
+func (c *CLI) getErrorExitCode(ctx context.Context, err error) int {
+	if err == nil {
+		return 0
+	}
+	var ee *ExitError
+	if errors.As(err, &ee) && ee.Err == nil {
+		return ee.Code
+	}
+	if ctx.Err() == nil || !errors.Is(err, ctx.Err()) {
+		c.printError(err)
+	}
+	return c.GetExitCode(err)
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Names of global flags added by AddLoggingFlags.
+const (
+	VerboseFlag   = "verbose"
+	QuietFlag     = "quiet"
+	LogLevelFlag  = "log-level"
+	LogFormatFlag = "log-format"
+)
+
+// countValue is a flag.Value of TypeCount flag that counts how many times
+// flag was passed.
+type countValue int
+
+func (v *countValue) String() string {
+	return strconv.Itoa(int(*v))
+}
+
+func (v *countValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if b {
+		*v++
+	} else {
+		*v = 0
+	}
+	return nil
+}
+
+func (v *countValue) IsBoolFlag() bool {
+	return true
+}
+
+var _ flag.Value = (*countValue)(nil)
+
+// IsTypeCount returns true when flag is of count type.
+func (c *CLIFlag) IsTypeCount() bool {
+	return c.nflags&TypeCount > 0
+}
+
+// AddLoggingFlags adds global flags that control the logger returned by
+// Logger method: --verbose (-v), which can be passed many times, --quiet
+// (-q), --log-level and --log-format. Default level is warn, each -v lowers
+// it by one step and --quiet sets it to error. --log-level overrides both.
+func (c *CLI) AddLoggingFlags() {
+	c.loggingFlags = true
+	c.AddGlobalFlag(VerboseFlag, "v", "", "Increase verbosity, can be passed many times", TypeCount, nil)
+	c.AddGlobalFlag(QuietFlag, "q", "", "Print errors only", TypeBool, nil)
+	c.AddGlobalFlag(LogLevelFlag, "", "LEVEL", "Log level: debug, info, warn or error", TypeAlphanumeric, nil)
+	c.AddGlobalFlag(LogFormatFlag, "", "FORMAT", "Log format: text or json", TypeAlphanumeric, nil)
+	c.AddPreRun(func(ctx context.Context, c *CLI) error {
+		if _, err := c.getLogLevel(); err != nil {
+			return err
+		}
+		switch c.Flag(LogFormatFlag) {
+		case "", "text", "json":
+			return nil
+		}
+		return &InvalidValueError{Flag: LogFormatFlag, Value: c.Flag(LogFormatFlag), Reason: "unknown log format"}
+	})
+}
+
+// getLogLevel returns log level depending on values of logging flags.
+func (c *CLI) getLogLevel() (slog.Level, error) {
+	if !c.loggingFlags {
+		return slog.LevelWarn, nil
+	}
+	if s := c.Flag(LogLevelFlag); s != "" {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(s)); err != nil {
+			return slog.LevelWarn, &InvalidValueError{Flag: LogLevelFlag, Value: s, Reason: "unknown log level"}
+		}
+		return l, nil
+	}
+	if c.Flag(QuietFlag) == "true" {
+		return slog.LevelError, nil
+	}
+	v, _ := strconv.Atoi(c.Flag(VerboseFlag))
+	l := slog.LevelWarn - slog.Level(4*v)
+	if l < slog.LevelDebug {
+		l = slog.LevelDebug
+	}
+	return l, nil
+}
+
+// newLogger creates logger writing to stderr with level and format depending
+// on values of logging flags.
+func (c *CLI) newLogger() *slog.Logger {
+	var w io.Writer = c.GetStderr()
+	if c.GetStderr() == nil {
+		w = os.Stderr
+	}
+	l, _ := c.getLogLevel()
+	opts := &slog.HandlerOptions{Level: l}
+	if c.loggingFlags && strings.ToLower(c.Flag(LogFormatFlag)) == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Logger returns logger writing to stderr. Its level and format can be
+// controlled with flags added by AddLoggingFlags.
+func (c *CLI) Logger() *slog.Logger {
+	if c.logger == nil {
+		c.logger = c.newLogger()
+	}
+	return c.logger
+}
+
+// logParsed logs values of parsed flags and arguments of cmd at debug level.
+// Values of secret flags are masked.
+func (c *CLI) logParsed(cmd *CLICmd) {
+	l := c.Logger()
+	if !l.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	var attrs []any
+	for _, f := range append(cmd.getFlagList(), c.getGlobalFlagList()...) {
+		attrs = append(attrs, slog.String("flag."+f.GetName(), f.MaskValue(c.Flag(f.GetName()))))
+	}
+	for _, n := range cmd.GetSortedArgs() {
+		attrs = append(attrs, slog.String("arg."+n, cmd.GetArg(n).MaskValue(c.Arg(n))))
+	}
+	l.Debug("Running command "+cmd.GetName(), attrs...)
+}
@@ -0,0 +1,404 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// Names of global flags added by AddOutputFlags.
+const (
+	OutputFlag  = "output"
+	ColumnsFlag = "columns"
+	SortByFlag  = "sort-by"
+)
+
+// Output formats that can be passed to --output flag. Template is passed as
+// template=TEMPLATE, eg. --output 'template={{.Name}}'.
+const (
+	OutputTable    = "table"
+	OutputJSON     = "json"
+	OutputJSONL    = "jsonl"
+	OutputYAML     = "yaml"
+	OutputCSV      = "csv"
+	OutputTemplate = "template"
+)
+
+// AddOutputFlags adds global flags --output, --columns and --sort-by that
+// control how values passed to Output method are printed. Global --output
+// replaces the built-in one added by AddVersion.
+func (c *CLI) AddOutputFlags() {
+	c.AddGlobalFlag(OutputFlag, "", "FORMAT", "Output format: table, json, jsonl, yaml, csv or template=TEMPLATE", TypeString, nil)
+	c.AddGlobalFlag(ColumnsFlag, "", "COL,COL,...", "Columns to print in table and csv output", TypeString, nil)
+	c.AddGlobalFlag(SortByFlag, "", "COL", "Column to sort table and csv output by, prefix with - for descending order", TypeString, nil)
+	c.AddPreRun(func(ctx context.Context, c *CLI) error {
+		f := c.getOutputFormat()
+		if isOutputFormat(f) {
+			return nil
+		}
+		return &InvalidValueError{Flag: OutputFlag, Value: f, Reason: "unknown output format"}
+	})
+}
+
+// getOutputFormat returns format passed with --output flag, without the
+// template.
+func (c *CLI) getOutputFormat() string {
+	f := c.Flag(OutputFlag)
+	if f == "" {
+		return OutputTable
+	}
+	return strings.SplitN(f, "=", 2)[0]
+}
+
+// isOutputFormat returns true when f is one of Output formats.
+func isOutputFormat(f string) bool {
+	switch f {
+	case OutputTable, OutputJSON, OutputJSONL, OutputYAML, OutputCSV, OutputTemplate:
+		return true
+	}
+	return false
+}
+
+// Output prints v to stdout in format passed with --output flag. Table is
+// the default format. For table and csv, v should be a struct, a map or a
+// slice of them; exported struct fields (named after their json tag if they
+// have one) or map keys become columns.
+func (c *CLI) Output(v interface{}) error {
+	return c.OutputTo(c.GetStdout(), v)
+}
+
+// OutputTo works like Output but prints to w.
+func (c *CLI) OutputTo(w io.Writer, v interface{}) error {
+	return writeOutput(w, v, c.Flag(OutputFlag), c.Flag(ColumnsFlag), c.Flag(SortByFlag))
+}
+
+// writeOutput writes v to w in format f, which is a value of --output flag.
+// Table is the default format. cs and sb are values of --columns and
+// --sort-by flags.
+func writeOutput(w io.Writer, v interface{}, f string, cs string, sb string) error {
+	p := strings.SplitN(f, "=", 2)
+	switch p[0] {
+	case OutputJSON:
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	case OutputJSONL:
+		return writeJSONLines(w, v)
+	case OutputYAML:
+		return writeYAML(w, v)
+	case OutputTemplate:
+		if len(p) < 2 {
+			return &InvalidValueError{Flag: OutputFlag, Value: p[0], Reason: "template is missing"}
+		}
+		t, err := template.New("output").Parse(p[1])
+		if err != nil {
+			return &InvalidValueError{Flag: OutputFlag, Value: p[1], Reason: err.Error()}
+		}
+		return t.Execute(w, v)
+	}
+
+	cols, rows := getRows(v)
+	cols, rows, err := selectColumns(cols, rows, cs)
+	if err != nil {
+		return err
+	}
+	err = sortRows(cols, rows, sb)
+	if err != nil {
+		return err
+	}
+	if p[0] == OutputCSV {
+		cw := csv.NewWriter(w)
+		cw.Write(cols)
+		cw.WriteAll(rows)
+		return cw.Error()
+	}
+	tw := new(tabwriter.Writer)
+	tw.Init(w, 0, 8, 2, ' ', 0)
+	hs := make([]string, len(cols))
+	for i, col := range cols {
+		hs[i] = strings.ToUpper(col)
+	}
+	fmt.Fprintln(tw, strings.Join(hs, "\t"))
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeJSONLines writes each element of slice v as a JSON in a separate
+// line. Other values are written in a single line.
+func writeJSONLines(w io.Writer, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return json.NewEncoder(w).Encode(v)
+	}
+	enc := json.NewEncoder(w)
+	for i := 0; i < rv.Len(); i++ {
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getRows converts v to column names and rows of values.
+func getRows(v interface{}) ([]string, [][]string) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	var items []reflect.Value
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		for i := 0; i < rv.Len(); i++ {
+			items = append(items, reflect.Indirect(rv.Index(i)))
+		}
+	} else if rv.IsValid() {
+		items = []reflect.Value{rv}
+	}
+
+	var cols []string
+	seen := make(map[string]bool)
+	maps := make([]map[string]string, len(items))
+	for i, it := range items {
+		m, ks := getRowValues(it)
+		maps[i] = m
+		for _, k := range ks {
+			if !seen[k] {
+				seen[k] = true
+				cols = append(cols, k)
+			}
+		}
+	}
+	rows := make([][]string, len(maps))
+	for i, m := range maps {
+		rows[i] = make([]string, len(cols))
+		for j, col := range cols {
+			rows[i][j] = m[col]
+		}
+	}
+	return cols, rows
+}
+
+// getRowValues returns values of struct fields or map keys, and their names
+// in order. Other values are returned in a "value" column.
+func getRowValues(v reflect.Value) (map[string]string, []string) {
+	m := make(map[string]string)
+	var ks []string
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+			n := sf.Name
+			if tag := strings.Split(sf.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				n = tag
+			}
+			m[n] = fmt.Sprint(v.Field(i).Interface())
+			ks = append(ks, n)
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			n := fmt.Sprint(k.Interface())
+			m[n] = fmt.Sprint(v.MapIndex(k).Interface())
+			ks = append(ks, n)
+		}
+		sort.Strings(ks)
+	default:
+		if v.IsValid() {
+			m["value"] = fmt.Sprint(v.Interface())
+			ks = append(ks, "value")
+		}
+	}
+	return m, ks
+}
+
+// findColumn returns index of column n, matched case-insensitively, or -1.
+func findColumn(cols []string, n string) int {
+	for i, col := range cols {
+		if strings.EqualFold(col, n) {
+			return i
+		}
+	}
+	return -1
+}
+
+// selectColumns returns only columns from comma-separated list s, in that
+// order. All columns are returned when s is empty.
+func selectColumns(cols []string, rows [][]string, s string) ([]string, [][]string, error) {
+	if s == "" {
+		return cols, rows, nil
+	}
+	var idx []int
+	for _, n := range strings.Split(s, ",") {
+		i := findColumn(cols, strings.TrimSpace(n))
+		if i == -1 {
+			return nil, nil, &InvalidValueError{Flag: ColumnsFlag, Value: n, Reason: "unknown column"}
+		}
+		idx = append(idx, i)
+	}
+	scols := make([]string, len(idx))
+	for j, i := range idx {
+		scols[j] = cols[i]
+	}
+	srows := make([][]string, len(rows))
+	for r := range rows {
+		srows[r] = make([]string, len(idx))
+		for j, i := range idx {
+			srows[r][j] = rows[r][i]
+		}
+	}
+	return scols, srows, nil
+}
+
+// sortRows sorts rows by column s. Values are compared as numbers when both
+// of them are numbers. Column prefixed with - sorts in descending order.
+func sortRows(cols []string, rows [][]string, s string) error {
+	if s == "" {
+		return nil
+	}
+	desc := strings.HasPrefix(s, "-")
+	i := findColumn(cols, strings.TrimPrefix(s, "-"))
+	if i == -1 {
+		return &InvalidValueError{Flag: SortByFlag, Value: s, Reason: "unknown column"}
+	}
+	sort.SliceStable(rows, func(a int, b int) bool {
+		va, vb := rows[a][i], rows[b][i]
+		if desc {
+			va, vb = vb, va
+		}
+		fa, erra := strconv.ParseFloat(va, 64)
+		fb, errb := strconv.ParseFloat(vb, 64)
+		if erra == nil && errb == nil {
+			return fa < fb
+		}
+		return va < vb
+	})
+	return nil
+}
+
+// writeYAML writes v as YAML. v is converted with encoding/json first, so
+// json tags are respected.
+func writeYAML(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var g interface{}
+	if err := dec.Decode(&g); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	writeYAMLValue(&buf, g, 0, false)
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// writeYAMLValue writes g at indentation level. inList is true when g is an
+// item of a list, so its first line follows "- ".
+func writeYAMLValue(buf *bytes.Buffer, g interface{}, level int, inList bool) {
+	ind := strings.Repeat("  ", level)
+	switch t := g.(type) {
+	case map[string]interface{}:
+		if len(t) == 0 {
+			buf.WriteString("{}\n")
+			return
+		}
+		ks := make([]string, 0, len(t))
+		for k := range t {
+			ks = append(ks, k)
+		}
+		sort.Strings(ks)
+		for i, k := range ks {
+			if i > 0 || !inList {
+				buf.WriteString(ind)
+			}
+			buf.WriteString(quoteYAML(k) + ":")
+			writeYAMLChild(buf, t[k], level)
+		}
+	case []interface{}:
+		if len(t) == 0 {
+			buf.WriteString("[]\n")
+			return
+		}
+		for i, e := range t {
+			if i > 0 || !inList {
+				buf.WriteString(ind)
+			}
+			buf.WriteString("- ")
+			writeYAMLValue(buf, e, level+1, true)
+		}
+	default:
+		buf.WriteString(formatYAMLScalar(g) + "\n")
+	}
+}
+
+// writeYAMLChild writes value of a map key.
+func writeYAMLChild(buf *bytes.Buffer, g interface{}, level int) {
+	switch t := g.(type) {
+	case map[string]interface{}:
+		if len(t) > 0 {
+			buf.WriteString("\n")
+			writeYAMLValue(buf, g, level+1, false)
+			return
+		}
+	case []interface{}:
+		if len(t) > 0 {
+			buf.WriteString("\n")
+			writeYAMLValue(buf, g, level, false)
+			return
+		}
+	}
+	buf.WriteString(" ")
+	writeYAMLValue(buf, g, level+1, true)
+}
+
+// formatYAMLScalar formats g as YAML scalar. Strings are always double
+// quoted, so they are never read back as another type.
+func formatYAMLScalar(g interface{}) string {
+	switch t := g.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(t)
+	case json.Number:
+		return t.String()
+	case string:
+		return strconv.Quote(t)
+	}
+	return strconv.Quote(fmt.Sprint(g))
+}
+
+// quoteYAML quotes map key s when it would not be read back as the same
+// string.
+func quoteYAML(s string) string {
+	if s == "" {
+		return "\"\""
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return strconv.Quote(s)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+	if strings.TrimSpace(s) != s || strings.ContainsAny(s, ":#{}[],&*!|>'\"%@`\n\t\\") || strings.ContainsAny(s[:1], "-?") {
+		return strconv.Quote(s)
+	}
+	return s
+}
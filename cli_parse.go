@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"os"
+)
+
+// FlagSource tells where value of a flag comes from.
+type FlagSource int
+
+const (
+	// SourceDefault means flag was not passed.
+	SourceDefault FlagSource = iota
+	// SourceName means flag was passed with its name, eg. --verbose.
+	SourceName
+	// SourceAlias means flag was passed with its alias, eg. -v.
+	SourceAlias
+	// SourcePrompt means value was entered in an interactive prompt.
+	SourcePrompt
+)
+
+// String returns name of the source.
+func (s FlagSource) String() string {
+	switch s {
+	case SourceName:
+		return "name"
+	case SourceAlias:
+		return "alias"
+	case SourcePrompt:
+		return "prompt"
+	}
+	return "default"
+}
+
+// getFlagSource returns source of flag value from values nv and av passed
+// with its name and alias.
+func getFlagSource(nv string, av string) FlagSource {
+	if nv != "" {
+		return SourceName
+	}
+	if av != "" {
+		return SourceAlias
+	}
+	return SourceDefault
+}
+
+// FlagSource returns where value of flag n comes from.
+func (c *CLI) FlagSource(n string) FlagSource {
+	return c.flagSources[n]
+}
+
+// FlagValue is a value of a flag in ParseResult.
+type FlagValue struct {
+	Value  string
+	Source FlagSource
+}
+
+// ParseResult is what args resolve to. Command is a name of the command that
+// would run, even when it was called with an alias, and it is empty when
+// args only print help or version. Help is true when args print help without
+// an error. Passthrough contains args that are after "--", which ends flags
+// of the command, and that are not values of arguments. Err is the first
+// error that was found.
+type ParseResult struct {
+	Command     string
+	Help        bool
+	Version     bool
+	Flags       map[string]FlagValue
+	Args        map[string]string
+	Passthrough []string
+	Err         error
+}
+
+// Parse parses args, which do not include the program name, in the same way
+// Run does but without running handlers, hooks and post validation.
+// Functions of bool flags are run on a copy of the command, so they change
+// how the rest of args is parsed, like in Run. Nothing is printed, prompts
+// are disabled, stdin is empty and CLI is not modified.
+func (c *CLI) Parse(args []string) *ParseResult {
+	res := &ParseResult{}
+	null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer null.Close()
+
+	dc := c.newDryRun(null)
+	r, err := dc.resolveArgs(args)
+	if r.cmd != nil {
+		res.Command = r.cmd.GetName()
+	}
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Help = r.action == actionHelp || r.action == actionHelpCmd
+	res.Version = r.action == actionVersion
+	if r.action != actionRun {
+		return res
+	}
+
+	cmd := r.cmd.clone()
+	res.Err = dc.parseFlags(cmd, r.args, r.gptrs)
+	if res.Err == flag.ErrHelp {
+		res.Help, res.Err = true, nil
+		return res
+	}
+	if _, ok := res.Err.(*versionRequest); ok {
+		res.Version, res.Err = true, nil
+		return res
+	}
+	res.Flags = make(map[string]FlagValue)
+	for n, v := range dc.parsedFlags {
+		res.Flags[n] = FlagValue{Value: v, Source: dc.flagSources[n]}
+	}
+	res.Args = dc.parsedArgs
+	if res.Args == nil {
+		res.Args = make(map[string]string)
+	}
+	res.Passthrough = dc.passthrough
+	if res.Passthrough == nil {
+		res.Passthrough = []string{}
+	}
+	return res
+}
+
+// newInvocation returns a copy of CLI for a single run, which has its own
+// parsed values, output files, logger and cleanup functions.
+func (c *CLI) newInvocation(stdout *os.File, stderr *os.File) *CLI {
+	inv := *c
+	inv.stdout, inv.stderr = stdout, stderr
+	inv.cmd = nil
+	inv.parsedFlags = make(map[string]string)
+	inv.parsedArgs = make(map[string]string)
+	inv.flagSources = make(map[string]FlagSource)
+	inv.noInput = false
+	inv.passthrough = nil
+	inv.logger = nil
+	inv.cleanups = append([]func(ctx context.Context){}, c.cleanups...)
+	return &inv
+}
+
+// newDryRun returns a copy of CLI that has no parsed values, prompts disabled
+// and stdin, stdout and stderr set to null. Post validation is not run when
+// flags are parsed with it.
+func (c *CLI) newDryRun(null *os.File) *CLI {
+	interactive := false
+	dc := c.newInvocation(null, null)
+	dc.dryRun = true
+	dc.SetStdin(null)
+	dc.interactive = &interactive
+	return dc
+}
+
+// getPassthrough returns args from rest, which were left after the flagset
+// parsed args, when "--" ended parsing of flags. First na of them, which are
+// values of arguments, are skipped.
+func getPassthrough(args []string, rest []string, na int) []string {
+	i := len(args) - len(rest)
+	if i == 0 || args[i-1] != "--" || len(rest) <= na {
+		return nil
+	}
+	return rest[na:]
+}
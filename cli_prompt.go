@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PromptText asks for a line of text.
+	PromptText = iota + 1
+	// PromptPassword asks for a line of text without echoing it.
+	PromptPassword
+	// PromptConfirm asks a yes/no question. Value is "true" or "false".
+	PromptConfirm
+	// PromptSelect asks to pick one of the choices.
+	PromptSelect
+	// PromptMultiSelect asks to pick one or more of the choices. Values are
+	// joined with the separator of AllowMany flag.
+	PromptMultiSelect
+)
+
+// NoInputFlag is name of the global flag that disables prompts. It is
+// available when any flag or argument has a prompt, unless a global flag with
+// the same name is added.
+const NoInputFlag = "no-input"
+
+// noInputFlag is the built-in global flag that disables prompts.
+var noInputFlag = NewCLIFlag(NoInputFlag, "", "", "Disable interactive prompts", TypeBool, nil)
+
+// prompt describes how to ask for a missing value.
+type prompt struct {
+	kind    int
+	msg     string
+	choices []string
+}
+
+// SetPrompt makes missing value of a required flag to be asked for when
+// application runs in a terminal. kind is one of Prompt constants, msg is
+// the question (description is used when it is empty) and choices are used
+// with PromptSelect and PromptMultiSelect. Echo is turned off for
+// PromptPassword and secret flags with stty, which is available only on
+// Unix-like systems.
+func (c *CLIFlag) SetPrompt(kind int, msg string, choices ...string) {
+	c.prompt = &prompt{kind: kind, msg: msg, choices: choices}
+}
+
+// HasPrompt returns true when flag has a prompt set.
+func (c *CLIFlag) HasPrompt() bool {
+	return c.prompt != nil
+}
+
+// SetInteractive forces prompts to be enabled or disabled. By default, they
+// are enabled when stdin is a terminal.
+func (c *CLI) SetInteractive(i bool) {
+	c.interactive = &i
+}
+
+// IsInteractive returns true when missing values can be prompted for.
+func (c *CLI) IsInteractive() bool {
+	if c.noInput {
+		return false
+	}
+	if c.interactive != nil {
+		return *c.interactive
+	}
+	return isTerminal(c.GetStdin())
+}
+
+// hasPrompts returns true when any flag or argument has a prompt set.
+func (c *CLI) hasPrompts() bool {
+	for _, n := range c.GetSortedCmds() {
+		cmd := c.GetCmd(n)
+		for _, f := range cmd.flags {
+			if f.HasPrompt() {
+				return true
+			}
+		}
+		for _, f := range cmd.args {
+			if f.HasPrompt() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isTerminal returns true when f is a terminal.
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// setEcho turns terminal echo on or off with stty, so it works only on
+// Unix-like systems that have it. Error is returned when echo could not be
+// changed.
+func setEcho(f *os.File, on bool) error {
+	if !isTerminal(f) {
+		return nil
+	}
+	if runtime.GOOS == "windows" {
+		return errors.New("turning off echo is not supported on " + runtime.GOOS)
+	}
+	a := "-echo"
+	if on {
+		a = "echo"
+	}
+	cmd := exec.Command("stty", a)
+	cmd.Stdin = f
+	return cmd.Run()
+}
+
+// getStdinReader returns buffered reader of stdin that is shared by prompts
+// and other features reading from stdin.
+func (c *CLI) getStdinReader() *bufio.Reader {
+	if c.stdinReader == nil {
+		c.stdinReader = bufio.NewReader(c.GetStdin())
+	}
+	return c.stdinReader
+}
+
+// readLine reads a line from stdin without the trailing newline. When
+// masked is true, terminal echo is turned off while reading. When it cannot be
+// turned off, a warning is printed and the line is read with echo on.
+func (c *CLI) readLine(masked bool) (string, error) {
+	r := c.getStdinReader()
+	if masked {
+		if err := setEcho(c.GetStdin(), false); err != nil {
+			fmt.Fprintln(c.stderr, "WARNING: Input will be visible: "+err.Error())
+		} else {
+			defer func() {
+				if err := setEcho(c.GetStdin(), true); err != nil {
+					fmt.Fprintln(c.stderr, "WARNING: Could not turn echo back on: "+err.Error())
+				}
+				fmt.Fprintf(c.stderr, "\n")
+			}()
+		}
+	}
+	l, err := r.ReadString('\n')
+	if err == io.EOF && l != "" {
+		err = nil
+	}
+	return strings.TrimRight(l, "\r\n"), err
+}
+
+// promptValue asks for value of flag f until it is valid. Empty value is
+// returned when stdin is closed.
+func (c *CLI) promptValue(f *CLIFlag, isArg bool) string {
+	p := f.prompt
+	msg := p.msg
+	if msg == "" {
+		msg = f.GetDesc()
+	}
+	for {
+		if p.kind == PromptSelect || p.kind == PromptMultiSelect {
+			for i, ch := range p.choices {
+				fmt.Fprintf(c.stderr, "  %d) %s\n", i+1, ch)
+			}
+		}
+		if p.kind == PromptConfirm {
+			fmt.Fprintf(c.stderr, "%s [y/N]: ", msg)
+		} else {
+			fmt.Fprintf(c.stderr, "%s: ", msg)
+		}
+		masked := p.kind == PromptPassword || f.IsSecret()
+		l, err := c.readLine(masked)
+		if err != nil {
+			return ""
+		}
+		v, ok := p.parse(strings.TrimSpace(l), f.getManySeparator())
+		if masked {
+			v, ok = l, true
+		}
+		if !ok {
+			fmt.Fprintf(c.stderr, "Invalid choice\n")
+			continue
+		}
+		err = f.ValidateValue(isArg, v, "")
+		if err != nil {
+			c.printError(err)
+			continue
+		}
+		return v
+	}
+}
+
+// parse converts answer to a value. It returns false when answer is not one
+// of the choices.
+func (p *prompt) parse(l string, sep string) (string, bool) {
+	switch p.kind {
+	case PromptConfirm:
+		switch strings.ToLower(l) {
+		case "y", "yes":
+			return "true", true
+		case "", "n", "no":
+			return "false", true
+		}
+		return "", false
+	case PromptSelect:
+		return p.choice(l)
+	case PromptMultiSelect:
+		var vs []string
+		for _, a := range strings.Split(l, ",") {
+			v, ok := p.choice(strings.TrimSpace(a))
+			if !ok {
+				return "", false
+			}
+			vs = append(vs, v)
+		}
+		return strings.Join(vs, sep), true
+	}
+	return l, true
+}
+
+// choice returns choice by its number or value.
+func (p *prompt) choice(a string) (string, bool) {
+	if i, err := strconv.Atoi(a); err == nil && i > 0 && i <= len(p.choices) {
+		return p.choices[i-1], true
+	}
+	for _, ch := range p.choices {
+		if ch == a {
+			return ch, true
+		}
+	}
+	return "", false
+}
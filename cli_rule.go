@@ -0,0 +1,83 @@
+package cli
+
+const (
+	ruleRequiredUnless = iota + 1
+	ruleRequiredIf
+)
+
+// requireRule is a rule that makes flag or argument target required
+// depending on value of flag.
+type requireRule struct {
+	kind   int
+	target string
+	flag   string
+	value  string
+}
+
+// AddRequiredUnless adds a rule that flag or argument n is required unless
+// flag f is passed. It replaces Required set on n.
+func (c *CLICmd) AddRequiredUnless(n string, f string) {
+	c.rules = append(c.rules, &requireRule{kind: ruleRequiredUnless, target: n, flag: f})
+}
+
+// AddRequiredIf adds a rule that flag or argument n is required if flag f
+// has value of v. Bool flags have value of "true" when they are passed. It
+// replaces Required set on n.
+func (c *CLICmd) AddRequiredIf(n string, f string, v string) {
+	c.rules = append(c.rules, &requireRule{kind: ruleRequiredIf, target: n, flag: f, value: v})
+}
+
+// applies returns true when the rule makes target required. value returns
+// value passed to a flag.
+func (r *requireRule) applies(value func(string) string) bool {
+	switch r.kind {
+	case ruleRequiredUnless:
+		return value(r.flag) == ""
+	case ruleRequiredIf:
+		return value(r.flag) == r.value
+	}
+	return false
+}
+
+// applyRules sets or unsets Required on flags and arguments depending on
+// rules attached to the command. It should be called on a copy of the
+// command so definitions are not modified.
+func (c *CLICmd) applyRules(value func(string) string) {
+	required := make(map[string]bool)
+	for _, r := range c.rules {
+		required[r.target] = required[r.target] || r.applies(value)
+	}
+	for n, req := range required {
+		f := c.GetFlag(n)
+		if f == nil {
+			f = c.GetArg(n)
+		}
+		if f == nil {
+			continue
+		}
+		if req {
+			f.SetNFlags(f.GetNFlags() | Required)
+		} else {
+			f.SetNFlags(f.GetNFlags() &^ Required)
+		}
+	}
+}
+
+// clone returns a copy of the command with copies of its flags and
+// arguments, so they can be modified while parsing without changing the
+// definition.
+func (c *CLICmd) clone() *CLICmd {
+	cc := *c
+	cc.flags = make(map[string]*CLIFlag, len(c.flags))
+	for n, f := range c.flags {
+		fc := *f
+		cc.flags[n] = &fc
+	}
+	cc.args = make(map[string]*CLIFlag, len(c.args))
+	for n, f := range c.args {
+		fc := *f
+		cc.args[n] = &fc
+	}
+	cc.argsOrder = append([]string{}, c.argsOrder...)
+	return &cc
+}
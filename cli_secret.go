@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// maskedValue replaces value of secret flags in output.
+const maskedValue = "********"
+
+// IsSecret returns true when flag value is secret.
+func (c *CLIFlag) IsSecret() bool {
+	return c.nflags&Secret > 0
+}
+
+// MaskValue returns v or a mask when flag is secret. It should be used
+// whenever flag value is printed.
+func (c *CLIFlag) MaskValue(v string) string {
+	if c.IsSecret() && v != "" {
+		return maskedValue
+	}
+	return v
+}
+
+// getFileFlagName returns name of the flag that reads value of secret flag
+// from a file.
+func (c *CLIFlag) getFileFlagName() string {
+	return c.GetName() + "-file"
+}
+
+// getSecretFileFlags returns flags that read values of secret flags in fs
+// from files.
+func getSecretFileFlags(fs []*CLIFlag) []*CLIFlag {
+	var sfs []*CLIFlag
+	for _, f := range fs {
+		if f.IsSecret() {
+			sfs = append(sfs, NewCLIFlag(f.getFileFlagName(), "", "PATH", "Read --"+f.GetName()+" from file", TypeString, nil))
+		}
+	}
+	return sfs
+}
+
+// resolveSecrets reads values of secret flags from fs that were passed with
+// -file suffix or with a value of "-", in which case value is read from
+// stdin. Values are stored in nptrs so they are validated like any other.
+func (c *CLI) resolveSecrets(fs []*CLIFlag, nptrs map[string]interface{}, aptrs map[string]interface{}) error {
+	for _, f := range fs {
+		if !f.IsSecret() || !f.IsRequireValue() {
+			continue
+		}
+		p, ok := nptrs[f.GetName()].(*string)
+		if !ok {
+			continue
+		}
+		nv, av := getFlagValues(f, nptrs, aptrs)
+		var path string
+		if pp, ok := nptrs[f.getFileFlagName()].(*string); ok {
+			path = *pp
+		}
+		if path != "" && (nv != "" || av != "") {
+			return &ConflictingFlagsError{Flags: []string{"--" + f.GetName(), "--" + f.getFileFlagName()}}
+		}
+		if path != "" {
+			b, err := ioutil.ReadFile(path)
+			if os.IsNotExist(err) {
+				return &PathNotFoundError{Flag: f.getFileFlagName(), Path: path}
+			}
+			if err != nil {
+				return err
+			}
+			*p = strings.TrimRight(string(b), "\r\n")
+			continue
+		}
+		if nv != "-" && av != "-" {
+			continue
+		}
+		b, err := ioutil.ReadAll(c.getStdinReader())
+		if err != nil {
+			return err
+		}
+		if pa, ok := aptrs[f.GetAlias()].(*string); ok && f.GetAlias() != "" {
+			*pa = ""
+		}
+		*p = strings.TrimRight(string(b), "\r\n")
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Names of commands that are available only in the shell started with
+// RunShell, unless there are commands with the same names.
+const (
+	ShellExitCmd    = "exit"
+	ShellQuitCmd    = "quit"
+	ShellHistoryCmd = "history"
+)
+
+// shellCmd is a command that is available only in the shell.
+type shellCmd struct {
+	name string
+	desc string
+	fn   func(c *CLI, args []string) int
+}
+
+// AddShellCmd adds command n with description d that is available only in
+// the shell started with RunShell. f gets args that were passed after the
+// command name and returns exit code.
+func (c *CLI) AddShellCmd(n string, d string, f func(c *CLI, args []string) int) {
+	if c.shellCmds == nil {
+		c.shellCmds = make(map[string]*shellCmd)
+	}
+	c.shellCmds[n] = &shellCmd{name: n, desc: d, fn: f}
+}
+
+// SetShellPrompt sets prompt that is printed by the shell before reading
+// a line. Default is program name followed by "> ".
+func (c *CLI) SetShellPrompt(p string) {
+	c.shellPrompt = p
+}
+
+// getShellPrompt returns prompt of the shell.
+func (c *CLI) getShellPrompt() string {
+	if c.shellPrompt != "" {
+		return c.shellPrompt
+	}
+	return path.Base(os.Args[0]) + "> "
+}
+
+// getShellCmdNames returns sorted names of shell-only commands, including
+// the built-in ones that are not replaced by commands.
+func (c *CLI) getShellCmdNames() []string {
+	var ns []string
+	for _, n := range []string{ShellExitCmd, ShellQuitCmd, ShellHistoryCmd} {
+		if c.FindCmd(n) == nil {
+			ns = append(ns, n)
+		}
+	}
+	for n := range c.shellCmds {
+		ns = append(ns, n)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// printShellCmdsHelp prints list of shell-only commands to w when CLI runs in
+// the shell.
+func (c *CLI) printShellCmdsHelp(w *tabwriter.Writer) {
+	if !c.inShell {
+		return
+	}
+	fmt.Fprintf(w, "\nShell commands:\n")
+	for _, n := range c.getShellCmdNames() {
+		fmt.Fprint(w, "  "+n+"\t"+c.getShellCmdDesc(n)+"\n")
+	}
+	w.Flush()
+}
+
+// getShellCmdDesc returns description of shell-only command n.
+func (c *CLI) getShellCmdDesc(n string) string {
+	switch n {
+	case ShellExitCmd, ShellQuitCmd:
+		return "Exits the shell with an optional exit code"
+	case ShellHistoryCmd:
+		return "Lists previous lines, !! runs the last one and !N runs line N"
+	}
+	return c.shellCmds[n].desc
+}
+
+// RunShell starts an interactive shell that reads lines from stdin, splits
+// them into args with Split and runs them as commands. Besides the
+// commands, exit (or quit) with an optional exit code, history, which lists
+// previous lines, and commands added with AddShellCmd are available. !! runs
+// the previous line again and !N runs line N from history. Prompt is printed
+// only when stdin is a terminal or SetInteractive(true) was called. Lines are
+// read as they are: line editing and tab completion are not supported, as
+// they need a terminal in raw mode. A line editor can use Complete and run
+// lines with RunArgs instead. Exit code of the last command is returned when
+// stdin is closed.
+func (c *CLI) RunShell(stdout *os.File, stderr *os.File) int {
+	c.warnInvalid(stderr)
+	sh := *c
+	sh.stdout, sh.stderr = stdout, stderr
+	sh.stdinReader = nil
+	sh.inShell = true
+	var history []string
+	code := 0
+	for {
+		if sh.IsInteractive() {
+			fmt.Fprint(stdout, sh.getShellPrompt())
+		}
+		l, err := sh.readLine(false)
+		if err != nil {
+			if sh.IsInteractive() {
+				fmt.Fprintf(stdout, "\n")
+			}
+			return code
+		}
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		el, err := expandHistory(l, history)
+		if err != nil {
+			sh.printError(err)
+			code = 1
+			continue
+		}
+		if el != l {
+			fmt.Fprintln(stdout, el)
+		}
+		history = append(history, el)
+
+		args, err := Split(el)
+		if err != nil {
+			sh.printError(err)
+			code = 1
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		n := args[0]
+		if c.FindCmd(n) != nil {
+			n = ""
+		}
+		switch n {
+		case ShellExitCmd, ShellQuitCmd:
+			if len(args) < 2 {
+				return code
+			}
+			i, err := strconv.Atoi(args[1])
+			if err != nil {
+				err = &InvalidValueError{Flag: "CODE", IsArg: true, Value: args[1], Reason: "exit code must be an integer"}
+				sh.printError(err)
+				code = 1
+				continue
+			}
+			return i
+		case ShellHistoryCmd:
+			for i, h := range history {
+				fmt.Fprintf(stdout, "%5d  %s\n", i+1, h)
+			}
+			code = 0
+		default:
+			if sc, ok := sh.shellCmds[args[0]]; ok {
+				code = sc.fn(&sh, args[1:])
+			} else {
+				code = sh.run(args, stdout, stderr)
+			}
+		}
+	}
+}
+
+// expandHistory replaces line l that is !! with the last line of history and
+// !N with line N.
+func expandHistory(l string, history []string) (string, error) {
+	if !strings.HasPrefix(l, "!") || len(l) < 2 {
+		return l, nil
+	}
+	i := len(history)
+	if l != "!!" {
+		var err error
+		i, err = strconv.Atoi(l[1:])
+		if err != nil {
+			return l, nil
+		}
+	}
+	if i < 1 || i > len(history) {
+		return "", errors.New("Event " + l + " not found in history")
+	}
+	return history[i-1], nil
+}
+
+// Completion is a candidate for completion returned by CompleteItems. Desc
+// is description of the command, flag or help topic and Category is title of
+// the section it is listed in by help, eg. "Commands" or "Global flags".
+type Completion struct {
+	Value    string
+	Desc     string
+	Category string
+}
+
+// Complete returns sorted candidates for the last word of line l, which is
+// an empty word when l ends with whitespace. Commands, their aliases and
+// shell-only commands are completed as the first word, command names and
+// help topics after help, and flags of the command and global flags when the
+// word starts with "-". RunShell does not use it, but it can be used by
+// a line editor that adds completion to the shell.
+func (c *CLI) Complete(l string) []string {
+	var res []string
+	for _, cp := range c.CompleteItems(l) {
+		res = append(res, cp.Value)
+	}
+	return res
+}
+
+// CompleteItems works like Complete but returns candidates with their
+// descriptions and categories, so they can be grouped like in help.
+func (c *CLI) CompleteItems(l string) []Completion {
+	words, err := Split(l)
+	if err != nil {
+		return nil
+	}
+	cur := ""
+	if len(words) > 0 && l != "" && !strings.ContainsAny(l[len(l)-1:], " \t") {
+		cur = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	// global flags can be before the command name
+	var cmd *CLICmd
+	var cands []Completion
+	hasCmd := false
+	for i, w := range words {
+		if strings.HasPrefix(w, "-") {
+			continue
+		}
+		hasCmd = true
+		if i == 0 && w == HelpCmd && c.hasHelpCmd() {
+			cands = c.getCmdCompletions()
+			for _, n := range c.GetSortedHelpTopics() {
+				cands = append(cands, Completion{Value: n, Desc: c.helpTopics[n].desc, Category: "Help topics"})
+			}
+			break
+		}
+		cmd = c.FindCmd(w)
+		break
+	}
+
+	if strings.HasPrefix(cur, "-") {
+		var fs []*CLIFlag
+		if cmd != nil {
+			fs = cmd.getFlagList()
+		}
+		for i, f := range append(fs, c.getGlobalFlagList()...) {
+			if f.IsHidden() || f.IsDeprecated() {
+				continue
+			}
+			ct := "Flags"
+			if i >= len(fs) {
+				ct = "Global flags"
+			}
+			cands = append(cands, Completion{Value: "--" + f.GetName(), Desc: f.GetDesc(), Category: ct})
+			if f.GetAlias() != "" && !strings.HasPrefix(cur, "--") {
+				cands = append(cands, Completion{Value: "-" + f.GetAlias(), Desc: f.GetDesc(), Category: ct})
+			}
+		}
+	} else if !hasCmd {
+		cands = c.getCmdCompletions()
+		if c.hasHelpCmd() {
+			cands = append(cands, Completion{Value: HelpCmd, Desc: "Prints help for a command or topic", Category: "Commands"})
+		}
+		if len(words) == 0 {
+			for _, n := range c.getShellCmdNames() {
+				cands = append(cands, Completion{Value: n, Desc: c.getShellCmdDesc(n), Category: "Shell commands"})
+			}
+		}
+	}
+
+	var res []Completion
+	seen := make(map[string]bool)
+	for _, cp := range cands {
+		if strings.HasPrefix(cp.Value, cur) && !seen[cp.Value] {
+			seen[cp.Value] = true
+			res = append(res, cp)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Value < res[j].Value
+	})
+	return res
+}
+
+// getCmdCompletions returns names and aliases of commands that are not
+// hidden with title of their category.
+func (c *CLI) getCmdCompletions() []Completion {
+	titles := make(map[string]string)
+	for _, ct := range c.getHelpCategories() {
+		titles[ct.name] = ct.title
+	}
+	var cs []Completion
+	for _, n := range c.GetSortedCmds() {
+		cmd := c.GetCmd(n)
+		if cmd.IsHidden() {
+			continue
+		}
+		for _, a := range append([]string{n}, cmd.GetAliases()...) {
+			cs = append(cs, Completion{Value: a, Desc: cmd.GetDesc(), Category: titles[cmd.GetCategory()]})
+		}
+	}
+	return cs
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultCleanupTimeout is how long cleanup hooks can run before they are
+// abandoned.
+const DefaultCleanupTimeout = 5 * time.Second
+
+// shutdownSignals are signals that are trapped while a command is running.
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+// osExit is used to force exit on a second signal.
+var osExit = os.Exit
+
+// AddCleanup registers a hook that is called after command finishes or is
+// interrupted by a signal. Hooks are called in reverse order of registration
+// and the context passed to them expires after the cleanup timeout.
+func (c *CLI) AddCleanup(fn func(ctx context.Context)) {
+	c.cleanups = append(c.cleanups, fn)
+}
+
+// SetCleanupTimeout sets how long cleanup hooks can run in total. Default is
+// DefaultCleanupTimeout.
+func (c *CLI) SetCleanupTimeout(d time.Duration) {
+	c.cleanupTimeout = d
+}
+
+// runCleanups calls cleanup hooks and waits for them until timeout passes.
+func (c *CLI) runCleanups() {
+	if len(c.cleanups) == 0 {
+		return
+	}
+	d := c.cleanupTimeout
+	if d == 0 {
+		d = DefaultCleanupTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for i := len(c.cleanups) - 1; i >= 0; i-- {
+			c.cleanups[i](ctx)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		c.Logger().Warn("Cleanup did not finish in " + d.String())
+	}
+}
+
+// execute runs command handler and traps SIGINT, SIGTERM and SIGHUP while
+// it runs. First signal cancels context passed to the handler and second one
+// exits immediately. Cleanup hooks are run after the handler returns, also
+// when it was interrupted, so handlers that do not take context keep running
+// until they finish or the second signal is received. When command was
+// interrupted by a signal, exit code is 128 plus signal number, eg. 130 for
+// SIGINT.
+func (c *CLI) execute(cmd *CLICmd) int {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, shutdownSignals...)
+	defer signal.Stop(sigs)
+
+	done := make(chan int, 1)
+	go func() {
+		done <- cmd.RunContext(ctx, c)
+	}()
+
+	var sig os.Signal
+	exitCode := 0
+wait:
+	for {
+		select {
+		case exitCode = <-done:
+			break wait
+		case s := <-sigs:
+			if sig != nil {
+				osExit(getSignalExitCode(s))
+				return getSignalExitCode(s)
+			}
+			sig = s
+			c.Logger().Debug("Received signal " + s.String())
+			cancel()
+		}
+	}
+
+	c.runCleanups()
+	if sig != nil {
+		return getSignalExitCode(sig)
+	}
+	return exitCode
+}
+
+// getSignalExitCode returns conventional exit code for process terminated by
+// signal s.
+func getSignalExitCode(s os.Signal) int {
+	if ss, ok := s.(syscall.Signal); ok {
+		return 128 + int(ss)
+	}
+	return 1
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+)
+
+// Split splits command line s into args like a POSIX shell does. Args are
+// separated with whitespace, backslash escapes the next character, single
+// quotes preserve everything until the closing quote, double quotes allow
+// escaping ", \, $ and ` with backslash and # at the beginning of an arg
+// starts a comment that lasts until end of line. Backslash followed by
+// a newline joins lines. It is used to split lines of the shell, response
+// files and examples.
+func Split(s string) ([]string, error) {
+	return split(s, nil)
+}
+
+// SplitEnv works like Split but expands $NAME and ${NAME} outside single
+// quotes with values returned by getenv, eg. os.Getenv. Expanded values are
+// not split into more args and an unquoted variable that is empty does not
+// make an arg.
+func SplitEnv(s string, getenv func(string) string) ([]string, error) {
+	return split(s, getenv)
+}
+
+// split splits s into args and expands variables when getenv is not nil.
+func split(s string, getenv func(string) string) ([]string, error) {
+	var args []string
+	var w strings.Builder
+	inArg := false
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, w.String())
+				w.Reset()
+				inArg = false
+			}
+		case r == '#' && !inArg:
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case r == '\\':
+			i++
+			if i >= len(rs) {
+				return nil, errors.New("unfinished escape")
+			}
+			if rs[i] != '\n' {
+				w.WriteRune(rs[i])
+				inArg = true
+			}
+		case r == '\'':
+			inArg = true
+			i++
+			for i < len(rs) && rs[i] != '\'' {
+				w.WriteRune(rs[i])
+				i++
+			}
+			if i >= len(rs) {
+				return nil, errors.New("unterminated single quote")
+			}
+		case r == '"':
+			inArg = true
+			i++
+			for i < len(rs) && rs[i] != '"' {
+				if rs[i] == '\\' && i+1 < len(rs) && strings.ContainsRune("\\\"$`\n", rs[i+1]) {
+					i++
+					if rs[i] != '\n' {
+						w.WriteRune(rs[i])
+					}
+					i++
+					continue
+				}
+				if rs[i] == '$' && getenv != nil {
+					v, n, err := expandVar(rs[i:], getenv)
+					if err != nil {
+						return nil, err
+					}
+					w.WriteString(v)
+					i += n
+					continue
+				}
+				w.WriteRune(rs[i])
+				i++
+			}
+			if i >= len(rs) {
+				return nil, errors.New("unterminated double quote")
+			}
+		case r == '$' && getenv != nil:
+			v, n, err := expandVar(rs[i:], getenv)
+			if err != nil {
+				return nil, err
+			}
+			w.WriteString(v)
+			if v != "" {
+				inArg = true
+			}
+			i += n - 1
+		default:
+			w.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, w.String())
+	}
+	return args, nil
+}
+
+// expandVar expands variable at the beginning of rs, which starts with $,
+// and returns its value and number of runes it takes. $ that is not followed
+// by a name is returned as it is.
+func expandVar(rs []rune, getenv func(string) string) (string, int, error) {
+	isName := func(r rune, first bool) bool {
+		return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (!first && r >= '0' && r <= '9')
+	}
+	if len(rs) > 1 && rs[1] == '{' {
+		end := -1
+		for j := 2; j < len(rs); j++ {
+			if rs[j] == '}' {
+				end = j
+				break
+			}
+		}
+		if end == -1 {
+			return "", 0, errors.New("unterminated ${")
+		}
+		n := string(rs[2:end])
+		for j, r := range n {
+			if !isName(r, j == 0) {
+				return "", 0, errors.New("invalid variable name " + n)
+			}
+		}
+		if n == "" {
+			return "", 0, errors.New("empty variable name")
+		}
+		return getenv(n), end + 1, nil
+	}
+	j := 1
+	for j < len(rs) && isName(rs[j], j == 1) {
+		j++
+	}
+	if j == 1 {
+		return "$", 1, nil
+	}
+	return getenv(string(rs[1:j])), j, nil
+}
+
+// Quote returns s quoted so that Split returns it as a single arg. s is
+// returned as it is when it contains only letters, digits and characters
+// that are safe in a shell, otherwise it is put in single quotes.
+func Quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && !strings.ContainsRune("_-+=.,/:@%", r) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Join quotes args with Quote and joins them with spaces. It is the inverse
+// of Split.
+func Join(args []string) string {
+	qs := make([]string, len(args))
+	for i, a := range args {
+		qs[i] = Quote(a)
+	}
+	return strings.Join(qs, " ")
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"io"
+	"strings"
+)
+
+// ArgIterator iterates over items of an argument. Items are read from stdin
+// when argument value is "-" or they are values separated like the ones of
+// AllowMany flag. Each item is validated with the argument type rules.
+type ArgIterator struct {
+	cli   *CLI
+	arg   *CLIFlag
+	items []string
+	stdin bool
+	sep   byte
+	item  string
+	err   error
+}
+
+// IsAllowStdin returns true when argument items can be read from stdin.
+func (c *CLIFlag) IsAllowStdin() bool {
+	return c.nflags&AllowStdin > 0
+}
+
+// ArgItems returns iterator over items of argument n of the command that is
+// run.
+func (c *CLI) ArgItems(n string) *ArgIterator {
+	it := &ArgIterator{cli: c, sep: '\n'}
+	if c.cmd != nil {
+		it.arg = c.cmd.GetArg(n)
+	}
+	if it.arg == nil {
+		return it
+	}
+	if it.arg.nflags&StdinSeparatorNUL > 0 {
+		it.sep = 0
+	}
+	v := c.Arg(n)
+	if v == "-" && it.arg.IsAllowStdin() {
+		it.stdin = true
+		return it
+	}
+	if v == "" {
+		return it
+	}
+	if it.arg.nflags&AllowMany > 0 {
+		it.items = strings.Split(v, it.arg.getManySeparator())
+	} else {
+		it.items = []string{v}
+	}
+	return it
+}
+
+// Next moves to the next item. It returns false when there are no more items
+// or when an error occurred, which is returned by Err.
+func (it *ArgIterator) Next() bool {
+	if it.err != nil || it.arg == nil {
+		return false
+	}
+	for {
+		var v string
+		if it.stdin {
+			l, err := it.cli.getStdinReader().ReadString(it.sep)
+			if err != nil && err != io.EOF {
+				it.err = err
+				return false
+			}
+			v = strings.TrimSuffix(l, string(it.sep))
+			if it.sep == '\n' {
+				v = strings.TrimSuffix(v, "\r")
+			}
+			if v == "" && err == io.EOF {
+				return false
+			}
+		} else {
+			if len(it.items) == 0 {
+				return false
+			}
+			v = it.items[0]
+			it.items = it.items[1:]
+		}
+		if v == "" {
+			continue
+		}
+		f := *it.arg
+		f.nflags &^= AllowMany | Required
+		if err := f.ValidateValue(true, v, ""); err != nil {
+			it.err = err
+			return false
+		}
+		it.item = v
+		return true
+	}
+}
+
+// Value returns current item.
+func (it *ArgIterator) Value() string {
+	return it.item
+}
+
+// Err returns error that stopped the iteration.
+func (it *ArgIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,80 @@
+package cli
+
+// levenshtein returns edit distance between strings a and b.
+func levenshtein(a string, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a int, b int, c int) int {
+	m := a
+	if b < m {
+		m = b
+	}
+	if c < m {
+		m = c
+	}
+	return m
+}
+
+// suggest returns the candidate that is closest to s or an empty string when
+// none of them is close enough to be worth suggesting.
+func suggest(s string, cands []string) string {
+	best := ""
+	bestDist := -1
+	for _, cand := range cands {
+		if cand == "" || cand == s {
+			continue
+		}
+		d := levenshtein(s, cand)
+		if d > 2 || d >= len(cand) {
+			continue
+		}
+		if bestDist == -1 || d < bestDist {
+			best = cand
+			bestDist = d
+		}
+	}
+	return best
+}
+
+// suggestFlag returns name (prefixed with --) or alias (prefixed with -) of
+// a flag from fs that is closest to n or an empty string if there is none.
+// Hidden and deprecated flags are not suggested.
+func suggestFlag(n string, fs []*CLIFlag) string {
+	var cands []string
+	prefixes := make(map[string]string)
+	for _, f := range fs {
+		if f.IsHidden() || f.IsDeprecated() {
+			continue
+		}
+		cands = append(cands, f.GetName())
+		prefixes[f.GetName()] = "--"
+		if f.GetAlias() != "" {
+			cands = append(cands, f.GetAlias())
+			prefixes[f.GetAlias()] = "-"
+		}
+	}
+	s := suggest(n, cands)
+	if s == "" {
+		return ""
+	}
+	return prefixes[s] + s
+}
@@ -1,16 +1,32 @@
 package cli
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
 	"os"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func h(c *CLI) int {
 	return 0
 }
 
+// newTestCLI returns CLI without commands that tests add their own to.
+func newTestCLI() *CLI {
+	return NewCLI("Example CLI", "Silly app", "Author <a@example.com>")
+}
+
 func createCLI() *CLI {
-	c := NewCLI("Example CLI", "Silly app", "Author <a@example.com>")
+	c := newTestCLI()
 
 	cmd1 := c.AddCmd("command", "Prints out something", h)
 	cmd1.AddFlag("bool", "b", "", "Boolean flag", TypeBool, nil)
@@ -49,10 +65,32 @@ func createCLI() *CLI {
 	return c
 }
 
+// openNull opens /dev/null that is closed when test finishes.
+func openNull(t *testing.T) *os.File {
+	f, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// tempFile creates a temporary file that is removed when test finishes.
+func tempFile(t *testing.T, n string) *os.File {
+	f, err := ioutil.TempFile("", n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		f.Close()
+		os.Remove(f.Name())
+	})
+	return f
+}
+
 func assertExitCode(t *testing.T, cli *CLI, a []string, c int) {
 	os.Args = a
-	f, _ := os.Open("/dev/null")
-	defer f.Close()
+	f := openNull(t)
 	got := cli.Run(f, f)
 	want := c
 	if got != want {
@@ -60,6 +98,19 @@ func assertExitCode(t *testing.T, cli *CLI, a []string, c int) {
 	}
 }
 
+// runWithStdout runs cli with args a and returns exit code and what was
+// printed to stdout.
+func runWithStdout(t *testing.T, cli *CLI, a []string) (int, string) {
+	os.Args = a
+	f := tempFile(t, "stdout")
+	code := cli.Run(f, openNull(t))
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, string(b)
+}
+
 func TestFlags(t *testing.T) {
 	c := createCLI()
 
@@ -103,3 +154,1220 @@ func TestFlags(t *testing.T) {
 		assertExitCode(t, c, []string{"test", "overwrite_arg", "-o"}, 0)
 	})
 }
+
+func TestUnknownFlags(t *testing.T) {
+	c := createCLI()
+
+	t.Run("exit with code 1 when flag is not defined", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "command", "-i", "cli_test.go", "-t", "title", "--titel", "x"}, 1)
+		assertExitCode(t, c, []string{"test", "anotherone", "--int", "1", "--float", "1.1", "--anum", "a", "-z"}, 1)
+	})
+}
+
+func TestSuggest(t *testing.T) {
+	c := createCLI()
+	cands := c.GetSortedCmds()
+
+	tests := map[string]string{
+		"comand":     "command",
+		"anotherune": "anotherone",
+		"pley":       "play",
+		"xyz":        "",
+	}
+	for s, want := range tests {
+		got := suggest(s, cands)
+		if got != want {
+			t.Errorf("suggest(%q) got %q want %q\n", s, got, want)
+		}
+	}
+
+	got := suggestFlag("titel", c.GetCmd("command").getFlagList())
+	if got != "--title" {
+		t.Errorf("got %q want %q\n", got, "--title")
+	}
+}
+
+func TestCmdAliases(t *testing.T) {
+	c := newTestCLI()
+	cmd := c.AddCmd("remove", "Removes something", func(c *CLI) int { return 3 })
+	cmd.AddAlias("rm")
+	cmd = c.AddCmd("internal", "Internal command", func(c *CLI) int { return 4 })
+	cmd.SetHidden(true)
+	cmd = c.AddCmd("delete", "Deletes something", func(c *CLI) int { return 5 })
+	cmd.SetDeprecated("remove")
+
+	t.Run("dispatch command by alias", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "remove"}, 3)
+		assertExitCode(t, c, []string{"test", "rm"}, 3)
+		assertExitCode(t, c, []string{"test", "rmm"}, 1)
+	})
+
+	t.Run("run hidden and deprecated commands", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "internal"}, 4)
+		assertExitCode(t, c, []string{"test", "delete"}, 5)
+	})
+
+	t.Run("do not suggest hidden commands", func(t *testing.T) {
+		got := suggest("internl", c.getVisibleCmdNames())
+		if got != "" {
+			t.Errorf("got %q want empty string\n", got)
+		}
+	})
+}
+
+func TestDeprecatedFlags(t *testing.T) {
+	c := newTestCLI()
+	cmd := c.AddCmd("start", "Starts something", func(c *CLI) int {
+		if c.Flag("user") != "bob" || c.Flag("verbose") != "true" || c.Flag("dir") != c.Flag("expect-dir") {
+			return 2
+		}
+		return 0
+	})
+	c.AddGlobalFlag("dir", "C", "path", "Directory", TypeString, nil)
+	c.AddGlobalFlag("workdir", "", "path", "Directory", TypeString, nil)
+	c.GetGlobalFlag("workdir").SetDeprecated("dir")
+	cmd.AddFlag("user", "u", "user", "User", TypeString|Required, nil)
+	cmd.AddFlag("username", "n", "user", "User", TypeString, nil)
+	cmd.GetFlag("username").SetDeprecated("user")
+	cmd.AddFlag("verbose", "v", "", "Verbose mode", TypeBool, nil)
+	cmd.AddFlag("debug", "", "", "Verbose mode", TypeBool|Hidden, nil)
+	cmd.GetFlag("debug").SetDeprecated("verbose")
+	cmd.AddFlag("expect-dir", "", "path", "Expected directory", TypeString, nil)
+
+	t.Run("map value of deprecated flag to its replacement", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "start", "--username", "bob", "-v"}, 0)
+		assertExitCode(t, c, []string{"test", "start", "-n", "bob", "--debug"}, 0)
+		assertExitCode(t, c, []string{"test", "start", "-u", "bob", "-n", "alice", "-v"}, 0)
+		assertExitCode(t, c, []string{"test", "--workdir", "/tmp", "start", "-u", "bob", "-v", "--expect-dir", "/tmp"}, 0)
+		assertExitCode(t, c, []string{"test", "start", "-u", "bob", "-v", "--workdir", "/tmp", "--expect-dir", "/tmp"}, 0)
+		assertExitCode(t, c, []string{"test", "start", "-u", "bob", "-v", "--workdir", "/tmp", "-C", "/usr", "--expect-dir", "/usr"}, 0)
+		assertExitCode(t, c, []string{"test", "-C", "/usr", "start", "-u", "bob", "-v", "--workdir", "/tmp", "--expect-dir", "/usr"}, 0)
+		assertExitCode(t, c, []string{"test", "--workdir", "/tmp", "start", "-u", "bob", "-v", "-C", "/usr", "--expect-dir", "/usr"}, 0)
+	})
+
+	t.Run("validate replacement of deprecated global flag", func(t *testing.T) {
+		if err := c.Validate(); err != nil {
+			t.Errorf("got %v want nil\n", err)
+		}
+		c.GetGlobalFlag("workdir").SetDeprecated("cwd")
+		if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "replacement global flag --cwd does not exist") {
+			t.Errorf("got %v want error about replacement\n", err)
+		}
+	})
+
+	t.Run("do not suggest hidden or deprecated flags", func(t *testing.T) {
+		got := suggestFlag("debu", cmd.getFlagList())
+		if got != "" {
+			t.Errorf("got %q want empty string\n", got)
+		}
+	})
+}
+
+func TestErrors(t *testing.T) {
+	t.Run("return typed errors from validation", func(t *testing.T) {
+		f := NewCLIFlag("input", "i", "file", "Input", TypePathFile|Required, nil)
+		var mfe *MissingFlagError
+		if err := f.ValidateValue(false, "", ""); !errors.As(err, &mfe) || mfe.Flag != "input" {
+			t.Errorf("got %v want MissingFlagError\n", err)
+		}
+		var pnfe *PathNotFoundError
+		if err := f.ValidateValue(false, "nonexisting", ""); !errors.As(err, &pnfe) || pnfe.Path != "nonexisting" {
+			t.Errorf("got %v want PathNotFoundError\n", err)
+		}
+		var cfe *ConflictingFlagsError
+		if err := f.ValidateValue(false, "a", "b"); !errors.As(err, &cfe) {
+			t.Errorf("got %v want ConflictingFlagsError\n", err)
+		}
+		f = NewCLIFlag("num", "n", "int", "Number", TypeInt, nil)
+		var ive *InvalidValueError
+		if err := f.ValidateValue(true, "abc", ""); !errors.As(err, &ive) || ive.Value != "abc" || !ive.IsArg {
+			t.Errorf("got %v want InvalidValueError\n", err)
+		}
+		f = NewCLIFlag("ids", "", "ID,...", "IDs", TypeAlphanumeric|AllowMany|AllowHyphen, nil)
+		if err := f.ValidateValue(false, "a-1,b_2", ""); !errors.As(err, &ive) || ive.Reason != "must be values separated with ',' that contain only letters, digits and hyphens" {
+			t.Errorf("got %v want InvalidValueError with description of the type\n", err)
+		}
+	})
+
+	t.Run("map error kinds to exit codes", func(t *testing.T) {
+		c := createCLI()
+		c.SetExitCode(KindMissingFlag, ExitUsage)
+		c.SetExitCode(KindPathNotFound, ExitNoInput)
+		c.SetExitCode(KindUnknownCommand, 127)
+		assertExitCode(t, c, []string{"test", "command", "-t", "title"}, ExitUsage)
+		assertExitCode(t, c, []string{"test", "command", "-i", "nonexistingfile", "-t", "title"}, ExitNoInput)
+		assertExitCode(t, c, []string{"test", "nonexisting"}, 127)
+		assertExitCode(t, c, []string{"test", "command", "-i", "cli_test.go", "-t", "title", "-z"}, 1)
+		assertExitCode(t, c, []string{"test", "command", "-i", "cli_test.go", "-t"}, ExitUsage)
+	})
+
+	t.Run("print errors with user input as it is", func(t *testing.T) {
+		c := createCLI()
+		stderr := tempFile(t, "stderr")
+		c.RunArgs([]string{"50%off"}, openNull(t), stderr)
+		b, _ := ioutil.ReadFile(stderr.Name())
+		if !strings.HasPrefix(string(b), "ERROR: Invalid command: 50%off\n") {
+			t.Errorf("got %q want error with command name\n", string(b))
+		}
+	})
+
+	t.Run("return typed errors from parsing flags", func(t *testing.T) {
+		c := createCLI()
+		var mfe *MissingFlagError
+		if err := c.Parse([]string{"command", "-i", "cli_test.go", "-t"}).Err; !errors.As(err, &mfe) || mfe.Flag != "title" {
+			t.Errorf("got %v want MissingFlagError\n", err)
+		}
+		var ufe *UnknownFlagError
+		if err := c.Parse([]string{"command", "--titel=x", "-t", "title"}).Err; !errors.As(err, &ufe) || ufe.Flag != "--titel" {
+			t.Errorf("got %v want UnknownFlagError\n", err)
+		}
+	})
+}
+
+func TestHandlerE(t *testing.T) {
+	c := newTestCLI()
+	c.SetExitCode(KindInvalidValue, ExitDataErr)
+	c.AddCmdE("ok", "Succeeds", func(ctx context.Context, c *CLI) error {
+		return ctx.Err()
+	})
+	c.AddCmdE("fail", "Fails", func(ctx context.Context, c *CLI) error {
+		return errors.New("failed")
+	})
+	c.AddCmdE("exit", "Exits with code", func(ctx context.Context, c *CLI) error {
+		return &ExitError{Code: 3}
+	})
+	c.AddCmdE("wrap", "Wraps an error", func(ctx context.Context, c *CLI) error {
+		return fmt.Errorf("wrapped: %w", &InvalidValueError{Flag: "x"})
+	})
+
+	assertExitCode(t, c, []string{"test", "ok"}, 0)
+	assertExitCode(t, c, []string{"test", "fail"}, 1)
+	assertExitCode(t, c, []string{"test", "exit"}, 3)
+	assertExitCode(t, c, []string{"test", "wrap"}, ExitDataErr)
+}
+
+func TestSignals(t *testing.T) {
+	c := newTestCLI()
+	cleaned := 0
+	c.AddCleanup(func(ctx context.Context) {
+		cleaned++
+	})
+	finallyCode := 0
+	c.AddFinally(func(c *CLI, exitCode int) {
+		finallyCode = exitCode
+	})
+	c.AddCmdE("wait", "Waits for a signal", func(ctx context.Context, c *CLI) error {
+		p, _ := os.FindProcess(os.Getpid())
+		p.Signal(os.Interrupt)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	c.AddCmd("ok", "Succeeds", h)
+	finished := false
+	c.AddCmd("legacy", "Finishes after a signal", func(c *CLI) int {
+		before := cleaned
+		p, _ := os.FindProcess(os.Getpid())
+		p.Signal(os.Interrupt)
+		time.Sleep(50 * time.Millisecond)
+		if cleaned != before {
+			t.Errorf("got cleanup before handler returned\n")
+		}
+		finished = true
+		return 0
+	})
+
+	t.Run("exit with code 130 when interrupted and run cleanup", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "wait"}, 130)
+		if cleaned != 1 || finallyCode != 130 {
+			t.Errorf("got %d cleanups and finally with %d want 1 and 130\n", cleaned, finallyCode)
+		}
+	})
+
+	t.Run("run cleanup after command finishes", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "ok"}, 0)
+		if cleaned != 2 {
+			t.Errorf("got %d cleanups want 2\n", cleaned)
+		}
+	})
+
+	t.Run("wait for handler without context before cleanup", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "legacy"}, 130)
+		if !finished || cleaned != 3 {
+			t.Errorf("got %v %d want handler finished and 3 cleanups\n", finished, cleaned)
+		}
+	})
+
+	t.Run("abandon cleanup after timeout", func(t *testing.T) {
+		c.SetCleanupTimeout(10 * time.Millisecond)
+		c.AddCleanup(func(ctx context.Context) {
+			time.Sleep(time.Second)
+		})
+		start := time.Now()
+		assertExitCode(t, c, []string{"test", "ok"}, 0)
+		if time.Since(start) > 500*time.Millisecond {
+			t.Errorf("cleanup was not abandoned after timeout\n")
+		}
+	})
+}
+
+func TestHooks(t *testing.T) {
+	var calls []string
+	record := func(s string) Hook {
+		return func(ctx context.Context, c *CLI) error {
+			calls = append(calls, s)
+			return nil
+		}
+	}
+	wrap := func(s string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, c *CLI) error {
+				calls = append(calls, s+":before")
+				err := next(ctx, c)
+				calls = append(calls, s+":after")
+				return err
+			}
+		}
+	}
+
+	c := newTestCLI()
+	c.AddPreRun(record("cli:pre"))
+	c.AddPostRun(record("cli:post"))
+	c.AddFinally(func(c *CLI, exitCode int) {
+		calls = append(calls, fmt.Sprintf("cli:finally:%d", exitCode))
+	})
+	c.Use(wrap("cli"))
+	cmd := c.AddCmd("run", "Runs", func(c *CLI) int {
+		calls = append(calls, "handler:"+c.Flag("name"))
+		return 0
+	})
+	cmd.AddFlag("name", "n", "name", "Name", TypeString, nil)
+	cmd.AddPreRun(record("cmd:pre"))
+	cmd.AddPostRun(record("cmd:post"))
+	cmd.AddFinally(func(c *CLI, exitCode int) {
+		calls = append(calls, fmt.Sprintf("cmd:finally:%d", exitCode))
+	})
+	cmd.Use(wrap("cmd"))
+	cmd = c.AddCmd("stop", "Stops", h)
+	cmd.AddPreRun(func(ctx context.Context, c *CLI) error {
+		return &ExitError{Code: 5}
+	})
+
+	t.Run("call hooks and middlewares in order", func(t *testing.T) {
+		calls = nil
+		assertExitCode(t, c, []string{"test", "run", "-n", "x"}, 0)
+		want := "cli:pre cmd:pre cli:before cmd:before handler:x cmd:after cli:after cmd:post cli:post cmd:finally:0 cli:finally:0"
+		if got := strings.Join(calls, " "); got != want {
+			t.Errorf("got %q want %q\n", got, want)
+		}
+	})
+
+	t.Run("short-circuit with exit code in pre-run hook", func(t *testing.T) {
+		calls = nil
+		assertExitCode(t, c, []string{"test", "stop"}, 5)
+		want := "cli:pre cli:finally:5"
+		if got := strings.Join(calls, " "); got != want {
+			t.Errorf("got %q want %q\n", got, want)
+		}
+	})
+
+	t.Run("call finally hooks when args are invalid", func(t *testing.T) {
+		calls = nil
+		assertExitCode(t, c, []string{"test", "run", "--unknown"}, 1)
+		assertExitCode(t, c, []string{"test", "unknown"}, 1)
+		want := "cmd:finally:1 cli:finally:1 cli:finally:1"
+		if got := strings.Join(calls, " "); got != want {
+			t.Errorf("got %q want %q\n", got, want)
+		}
+	})
+}
+
+func TestGlobalFlags(t *testing.T) {
+	c := newTestCLI()
+	c.AddGlobalFlag("verbose", "v", "", "Verbose mode", TypeBool, nil)
+	c.AddGlobalFlag("dir", "C", "path", "Working directory", TypeString, nil)
+	c.AddGlobalFlag("level", "", "int", "Level", TypeInt, nil)
+	cmd := c.AddCmd("build", "Builds", func(c *CLI) int {
+		if c.Flag("verbose") != "true" || c.Flag("dir") != "/tmp" {
+			return 2
+		}
+		return 0
+	})
+	cmd.AddFlag("target", "t", "target", "Target", TypeString, nil)
+
+	t.Run("parse global flags before and after command name", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "--verbose", "-C", "/tmp", "build"}, 0)
+		assertExitCode(t, c, []string{"test", "build", "-v", "--dir", "/tmp", "-t", "x"}, 0)
+		assertExitCode(t, c, []string{"test", "-v", "build", "-C", "/tmp"}, 0)
+		assertExitCode(t, c, []string{"test", "-C", "/var", "build", "-v", "-C", "/tmp"}, 0)
+		assertExitCode(t, c, []string{"test", "build", "-t", "x"}, 2)
+	})
+
+	t.Run("exit with code 1 when global flag is invalid", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "--level", "x", "build"}, 1)
+		assertExitCode(t, c, []string{"test", "build", "--level", "x"}, 1)
+		assertExitCode(t, c, []string{"test", "--unknown", "build"}, 1)
+	})
+}
+
+func TestFlagGroups(t *testing.T) {
+	c := newTestCLI()
+	cmd := c.AddCmd("fetch", "Fetches", h)
+	cmd.AddFlag("file", "f", "path", "File", TypeString, nil)
+	cmd.AddFlag("url", "u", "url", "URL", TypeString, nil)
+	cmd.AddFlag("user", "", "user", "User", TypeString, nil)
+	cmd.AddFlag("password", "", "password", "Password", TypeString, nil)
+	cmd.AddFlag("json", "", "", "JSON output", TypeBool, nil)
+	cmd.AddFlag("table", "", "", "Table output", TypeBool, nil)
+	cmd.AddFlag("cert", "", "path", "Certificate", TypeString, nil)
+	cmd.AddFlag("key", "", "path", "Key", TypeString, nil)
+	cmd.AddExactlyOne("file", "url")
+	cmd.AddRequires("user", "password")
+	cmd.AddConflicts("json", "table")
+	cmd.AddAllOrNone("cert", "key")
+
+	t.Run("exit with code 0 when rules are met", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "fetch", "-f", "x"}, 0)
+		assertExitCode(t, c, []string{"test", "fetch", "-u", "x", "--user", "a", "--password", "b", "--json"}, 0)
+		assertExitCode(t, c, []string{"test", "fetch", "-u", "x", "--cert", "a", "--key", "b", "--table"}, 0)
+	})
+
+	t.Run("exit with code 1 when rules are not met", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "fetch"}, 1)
+		assertExitCode(t, c, []string{"test", "fetch", "-f", "x", "-u", "x"}, 1)
+		assertExitCode(t, c, []string{"test", "fetch", "-f", "x", "--user", "a"}, 1)
+		assertExitCode(t, c, []string{"test", "fetch", "-f", "x", "--json", "--table"}, 1)
+		assertExitCode(t, c, []string{"test", "fetch", "-f", "x", "--cert", "a"}, 1)
+	})
+
+	t.Run("treat count flag as passed only when it is greater than 0", func(t *testing.T) {
+		c := newTestCLI()
+		cmd := c.AddCmd("run", "Runs", h)
+		cmd.AddFlag("verbose", "v", "", "Verbosity", TypeCount, nil)
+		cmd.AddFlag("quiet", "q", "", "Quiet", TypeBool, nil)
+		cmd.AddMutuallyExclusive("verbose", "quiet")
+		assertExitCode(t, c, []string{"test", "run", "-q"}, 0)
+		assertExitCode(t, c, []string{"test", "run", "-v", "-v"}, 0)
+		assertExitCode(t, c, []string{"test", "run", "-v", "-q"}, 1)
+	})
+
+	t.Run("return typed errors", func(t *testing.T) {
+		g := &flagGroup{kind: groupMutuallyExclusive, flags: []string{"a", "b"}}
+		var cfe *ConflictingFlagsError
+		if err := g.validate(func(string) bool { return true }); !errors.As(err, &cfe) || len(cfe.Flags) != 2 {
+			t.Errorf("got %v want ConflictingFlagsError\n", err)
+		}
+		g = &flagGroup{kind: groupAtLeastOne, flags: []string{"a", "b"}}
+		var fge *FlagGroupError
+		if err := g.validate(func(string) bool { return false }); !errors.As(err, &fge) {
+			t.Errorf("got %v want FlagGroupError\n", err)
+		}
+	})
+}
+
+func TestRequireRules(t *testing.T) {
+	c := createCLI()
+
+	t.Run("do not modify definition when flag callback is called", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "overwrite_arg", "-o"}, 0)
+		assertExitCode(t, c, []string{"test", "overwrite_arg"}, 1)
+	})
+
+	c = newTestCLI()
+	cmd := c.AddCmd("deploy", "Deploys", h)
+	cmd.AddFlag("all", "a", "", "Deploy everything", TypeBool, nil)
+	cmd.AddFlag("mode", "m", "mode", "Mode", TypeString, nil)
+	cmd.AddFlag("token", "t", "token", "Token", TypeString, nil)
+	cmd.AddArg("target", "TARGET", "Target to deploy", TypeString|Required)
+	cmd.AddRequiredUnless("target", "all")
+	cmd.AddRequiredIf("token", "mode", "remote")
+
+	t.Run("require argument unless flag is passed", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "deploy", "web"}, 0)
+		assertExitCode(t, c, []string{"test", "deploy", "-a"}, 0)
+		assertExitCode(t, c, []string{"test", "deploy"}, 1)
+	})
+
+	t.Run("require flag if another flag has value", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "deploy", "-m", "local", "web"}, 0)
+		assertExitCode(t, c, []string{"test", "deploy", "-m", "remote", "-t", "x", "web"}, 0)
+		assertExitCode(t, c, []string{"test", "deploy", "-m", "remote", "web"}, 1)
+	})
+
+	if !cmd.GetArg("target").IsRequired() || cmd.GetFlag("token").IsRequired() {
+		t.Errorf("definition was modified\n")
+	}
+}
+
+func withStdin(t *testing.T, c *CLI, in string) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString(in)
+	w.Close()
+	c.SetStdin(r)
+}
+
+func TestPrompts(t *testing.T) {
+	var got map[string]string
+	c := newTestCLI()
+	cmd := c.AddCmd("login", "Logs in", func(c *CLI) int {
+		got = map[string]string{"user": c.Flag("user"), "port": c.Flag("port"), "env": c.Flag("env"), "regions": c.Flag("regions"), "sure": c.Arg("sure")}
+		return 0
+	})
+	cmd.AddFlag("user", "u", "user", "User", TypeString|Required, nil)
+	cmd.GetFlag("user").SetPrompt(PromptText, "Username")
+	cmd.AddFlag("port", "p", "port", "Port", TypeInt|Required, nil)
+	cmd.GetFlag("port").SetPrompt(PromptPassword, "")
+	cmd.AddFlag("env", "e", "env", "Environment", TypeAlphanumeric|Required, nil)
+	cmd.GetFlag("env").SetPrompt(PromptSelect, "Environment", "dev", "prod")
+	cmd.AddFlag("regions", "r", "regions", "Regions", TypeAlphanumeric|AllowMany|Required, nil)
+	cmd.GetFlag("regions").SetPrompt(PromptMultiSelect, "Regions", "eu", "us", "ap")
+	cmd.AddArg("sure", "SURE", "Confirmation", TypeString|Required)
+	cmd.GetArg("sure").SetPrompt(PromptConfirm, "Are you sure?")
+
+	t.Run("prompt for missing values", func(t *testing.T) {
+		c.SetInteractive(true)
+		withStdin(t, c, "prod\nx\n8080\n1,ap\nbob\ny\n")
+		assertExitCode(t, c, []string{"test", "login"}, 0)
+		want := map[string]string{"user": "bob", "port": "8080", "env": "prod", "regions": "eu,ap", "sure": "true"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v want %v\n", got, want)
+		}
+	})
+
+	t.Run("exit with code 1 when input ends", func(t *testing.T) {
+		withStdin(t, c, "prod\n")
+		assertExitCode(t, c, []string{"test", "login"}, 1)
+	})
+
+	t.Run("do not prompt when disabled", func(t *testing.T) {
+		withStdin(t, c, "prod\n1,ap\n8080\nbob\ny\n")
+		assertExitCode(t, c, []string{"test", "--no-input", "login"}, 1)
+		c.SetInteractive(false)
+		withStdin(t, c, "prod\n1,ap\n8080\nbob\ny\n")
+		assertExitCode(t, c, []string{"test", "login"}, 1)
+		if _, ok := c.globalFlags[NoInputFlag]; ok || c.GetGlobalFlag(NoInputFlag) == nil {
+			t.Errorf("got --no-input added to definition want built-in flag\n")
+		}
+	})
+}
+
+func TestSecrets(t *testing.T) {
+	var got string
+	c := newTestCLI()
+	cmd := c.AddCmd("login", "Logs in", func(c *CLI) int {
+		got = c.Flag("token")
+		return 0
+	})
+	cmd.AddFlag("token", "t", "token", "Token", TypeAlphanumeric|Secret|Required, nil)
+
+	f := tempFile(t, "token")
+	f.WriteString("fromfile\n")
+	f.Close()
+
+	t.Run("read secret value from file or stdin", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "login", "--token-file", f.Name()}, 0)
+		if got != "fromfile" {
+			t.Errorf("got %q want %q\n", got, "fromfile")
+		}
+		withStdin(t, c, "fromstdin\n")
+		assertExitCode(t, c, []string{"test", "login", "-t", "-"}, 0)
+		if got != "fromstdin" {
+			t.Errorf("got %q want %q\n", got, "fromstdin")
+		}
+	})
+
+	t.Run("exit with code 1 when secret is passed twice or file does not exist", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "login", "-t", "abc", "--token-file", f.Name()}, 1)
+		assertExitCode(t, c, []string{"test", "login", "--token-file", "nonexisting"}, 1)
+	})
+
+	t.Run("mask secret value in errors", func(t *testing.T) {
+		err := cmd.GetFlag("token").ValidateValue(false, "s3cr3t!", "")
+		var ive *InvalidValueError
+		if !errors.As(err, &ive) || ive.Value != maskedValue {
+			t.Errorf("got %v want masked InvalidValueError\n", err)
+		}
+	})
+}
+
+func TestFileValues(t *testing.T) {
+	var got []string
+	c := newTestCLI()
+	c.SetResponseFiles(true)
+	cmd := c.AddCmd("deploy", "Deploys", func(c *CLI) int {
+		got = []string{c.Flag("name"), c.Flag("body"), c.Arg("target")}
+		return 0
+	})
+	cmd.AddFlag("name", "n", "name", "Name", TypeString, nil)
+	cmd.AddFlag("body", "b", "json", "Body", TypeString|AllowFileValue, nil)
+	cmd.AddArg("target", "TARGET", "Target", TypeString|AllowFileValue)
+
+	dir, err := ioutil.TempDir("", "cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(n string, s string) string {
+		p := dir + "/" + n
+		ioutil.WriteFile(p, []byte(s), 0600)
+		return p
+	}
+	body := write("body.json", "{\"a\": 1}\n")
+	nested := write("nested.txt", "-b @"+body+" # comment\n")
+	args := write("args.txt", "deploy --name 'bob smith' @"+nested+" \"@@@web\"\n")
+	loop := write("loop.txt", "@"+dir+"/loop.txt")
+
+	t.Run("expand response files and read values from files", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "@" + args}, 0)
+		want := []string{"bob smith", "{\"a\": 1}", "@web"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q want %q\n", got, want)
+		}
+		assertExitCode(t, c, []string{"test", "deploy", "@@" + body}, 0)
+		if got[2] != "{\"a\": 1}" {
+			t.Errorf("got %q want %q\n", got[2], "{\"a\": 1}")
+		}
+		assertExitCode(t, c, []string{"test", "deploy", "--body", "@" + body, "x"}, 0)
+		if got[1] != "{\"a\": 1}" {
+			t.Errorf("got %q want %q\n", got[1], "{\"a\": 1}")
+		}
+		assertExitCode(t, c, []string{"test", "deploy", "-b", "@@web", "x"}, 0)
+		if got[1] != "@web" {
+			t.Errorf("got %q want %q\n", got[1], "@web")
+		}
+	})
+
+	t.Run("exit with code 1 when file does not exist or nesting is too deep", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "@nonexisting"}, 1)
+		assertExitCode(t, c, []string{"test", "deploy", "-b", "@nonexisting"}, 1)
+		assertExitCode(t, c, []string{"test", "@" + loop}, 1)
+	})
+}
+
+func TestArgItems(t *testing.T) {
+	var got []string
+	var gotErr error
+	handler := func(c *CLI) int {
+		got = nil
+		it := c.ArgItems("files")
+		for it.Next() {
+			got = append(got, it.Value())
+		}
+		gotErr = it.Err()
+		return 0
+	}
+	c := newTestCLI()
+	cmd := c.AddCmd("compress", "Compresses", handler)
+	cmd.AddArg("files", "FILES", "Files", TypeAlphanumeric|AllowDots|AllowMany|AllowStdin|Required)
+	cmd = c.AddCmd("compress0", "Compresses", handler)
+	cmd.AddArg("files", "FILES", "Files", TypeAlphanumeric|AllowDots|AllowStdin|StdinSeparatorNUL|Required)
+
+	t.Run("read items from stdin", func(t *testing.T) {
+		withStdin(t, c, "a.log\nb.log\r\n\nc.log")
+		assertExitCode(t, c, []string{"test", "compress", "-"}, 0)
+		if want := []string{"a.log", "b.log", "c.log"}; !reflect.DeepEqual(got, want) || gotErr != nil {
+			t.Errorf("got %q, %v want %q\n", got, gotErr, want)
+		}
+		withStdin(t, c, "a.log\x00b.log\x00")
+		assertExitCode(t, c, []string{"test", "compress0", "-"}, 0)
+		if want := []string{"a.log", "b.log"}; !reflect.DeepEqual(got, want) || gotErr != nil {
+			t.Errorf("got %q, %v want %q\n", got, gotErr, want)
+		}
+	})
+
+	t.Run("iterate over items passed as argument", func(t *testing.T) {
+		assertExitCode(t, c, []string{"test", "compress", "a.log,b.log"}, 0)
+		if want := []string{"a.log", "b.log"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q want %q\n", got, want)
+		}
+	})
+
+	t.Run("stop on invalid item", func(t *testing.T) {
+		withStdin(t, c, "a.log\nb/log\nc.log\n")
+		assertExitCode(t, c, []string{"test", "compress", "-"}, 0)
+		var ive *InvalidValueError
+		if want := []string{"a.log"}; !reflect.DeepEqual(got, want) || !errors.As(gotErr, &ive) {
+			t.Errorf("got %q, %v want %q and InvalidValueError\n", got, gotErr, want)
+		}
+	})
+}
+
+type testUser struct {
+	Name  string `json:"name"`
+	Age   int    `json:"age"`
+	Tags  []string
+	token string
+}
+
+func TestOutput(t *testing.T) {
+	users := []testUser{
+		{Name: "bob", Age: 42, Tags: []string{"admin"}},
+		{Name: "alice smith", Age: 7},
+	}
+	var buf bytes.Buffer
+	var outErr error
+	c := newTestCLI()
+	c.AddOutputFlags()
+	c.AddCmd("list", "Lists users", func(c *CLI) int {
+		buf.Reset()
+		outErr = c.OutputTo(&buf, users)
+		return 0
+	})
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{}, "NAME         AGE  TAGS\nbob          42   [admin]\nalice smith  7    []\n"},
+		{[]string{"--columns", "age,name", "--sort-by", "age"}, "AGE  NAME\n7    alice smith\n42   bob\n"},
+		{[]string{"--output", "csv", "--sort-by", "-name", "--columns", "name"}, "name\nbob\nalice smith\n"},
+		{[]string{"--output", "jsonl"}, "{\"name\":\"bob\",\"age\":42,\"Tags\":[\"admin\"]}\n{\"name\":\"alice smith\",\"age\":7,\"Tags\":null}\n"},
+		{[]string{"--output", "yaml"}, "- Tags:\n  - \"admin\"\n  age: 42\n  name: \"bob\"\n- Tags: null\n  age: 7\n  name: \"alice smith\"\n"},
+		{[]string{"--output", "template={{range .}}{{.Name}};{{end}}"}, "bob;alice smith;"},
+	}
+	for _, tt := range tests {
+		assertExitCode(t, c, append([]string{"test", "list"}, tt.args...), 0)
+		if buf.String() != tt.want || outErr != nil {
+			t.Errorf("%v: got %q, %v want %q\n", tt.args, buf.String(), outErr, tt.want)
+		}
+	}
+
+	var yb bytes.Buffer
+	err := writeYAML(&yb, map[string]string{"yes": "no", "a": "null", "b": ":x #y", "c": "@z", "d": "1\n2"})
+	want := "a: \"null\"\nb: \":x #y\"\nc: \"@z\"\nd: \"1\\n2\"\n\"yes\": \"no\"\n"
+	if err != nil || yb.String() != want {
+		t.Errorf("got %q, %v want %q\n", yb.String(), err, want)
+	}
+
+	assertExitCode(t, c, []string{"test", "list", "--output", "xml"}, 1)
+	assertExitCode(t, c, []string{"test", "list", "--columns", "email"}, 0)
+	var ive *InvalidValueError
+	if !errors.As(outErr, &ive) {
+		t.Errorf("got %v want InvalidValueError\n", outErr)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var levels []bool
+	c := newTestCLI()
+	c.AddLoggingFlags()
+	c.AddCmd("run", "Runs", func(c *CLI) int {
+		ctx := context.Background()
+		levels = []bool{
+			c.Logger().Enabled(ctx, slog.LevelDebug),
+			c.Logger().Enabled(ctx, slog.LevelInfo),
+			c.Logger().Enabled(ctx, slog.LevelWarn),
+		}
+		return 0
+	})
+
+	tests := []struct {
+		args []string
+		want []bool
+	}{
+		{[]string{}, []bool{false, false, true}},
+		{[]string{"-v"}, []bool{false, true, true}},
+		{[]string{"-v", "--verbose", "-v"}, []bool{true, true, true}},
+		{[]string{"-q"}, []bool{false, false, false}},
+		{[]string{"-q", "--log-level", "debug", "--log-format", "json"}, []bool{true, true, true}},
+	}
+	for _, tt := range tests {
+		assertExitCode(t, c, append([]string{"test", "run"}, tt.args...), 0)
+		if !reflect.DeepEqual(levels, tt.want) {
+			t.Errorf("%v: got %v want %v\n", tt.args, levels, tt.want)
+		}
+	}
+	assertExitCode(t, c, []string{"test", "run", "--log-level", "loud"}, 1)
+	assertExitCode(t, c, []string{"test", "run", "--log-format", "xml"}, 1)
+}
+
+func TestValidate(t *testing.T) {
+	c := createCLI()
+	if err := c.Validate(); err != nil {
+		t.Errorf("got %v want nil\n", err)
+	}
+
+	c = newTestCLI()
+	c.AddGlobalFlag("verbose", "v", "", "Verbose", TypeBool, nil)
+	cmd := c.AddCmd("run", "Runs", h)
+	cmd.AddAlias("start")
+	c.AddCmd("start", "Starts", h)
+	cmd.AddFlag("input", "i", "FILE", "Input", TypePathFile, nil)
+	cmd.AddFlag("many-ints", "i", "INT,...", "Integers", TypeInt|AllowMany, nil)
+	cmd.AddFlag("host", "h", "HOST", "Host", TypeString, nil)
+	cmd.AddFlag("count", "", "NUM", "Count", TypeInt|TypeFloat, nil)
+	cmd.AddFlag("name", "", "NAME", "Name", TypeString|AllowDots, nil)
+	cmd.AddFlag("value", "v", "VALUE", "Value", TypeString, nil)
+	cmd.AddFlag("", "", "", "Empty", TypeString, nil)
+	cmd.AddFlag("name", "", "NAME", "Name", TypeString|AllowDots, nil)
+	cmd.AddArg("first", "FIRST", "First", TypeString)
+	cmd.AddArg("second", "SECOND", "Second", TypeString|Required)
+	for i := 0; i < 9; i++ {
+		cmd.AddArg(fmt.Sprintf("arg%d", i), "ARG", "Argument", TypeString)
+	}
+	cmd.AddMutuallyExclusive("input", "url")
+
+	err := c.Validate()
+	var de *DefinitionError
+	if !errors.As(err, &de) {
+		t.Fatalf("got %v want DefinitionError\n", err)
+	}
+	for _, s := range []string{
+		"command start: name or alias start is already used by command",
+		"flag --many-ints: name or alias i is already used by flag --input",
+		"flag --host: alias -h is reserved for help",
+		"flag --count: conflicting types TypeInt, TypeFloat",
+		"flag --name: AllowDots requires TypeAlphanumeric",
+		"alias -v collides with global flag --verbose",
+		"flag --: name is empty",
+		"flag --name: flag is added more than once",
+		"argument second: required argument is added after optional argument first",
+		"argument arg8: only 10 arguments are allowed",
+		"flag --url used in flag rule does not exist",
+	} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("got %v want it to contain %q\n", err, s)
+		}
+	}
+	assertExitCode(t, c, []string{"test", "run", "second"}, 0)
+}
+
+func TestVersion(t *testing.T) {
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			GoVersion: "go1.21.0",
+			Main:      debug.Module{Version: "v1.2.3"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "abc123"},
+				{Key: "vcs.modified", Value: "true"},
+				{Key: "vcs.time", Value: "2024-01-02T03:04:05Z"},
+			},
+		}, true
+	}
+	defer func() { readBuildInfo = debug.ReadBuildInfo }()
+
+	c := createCLI()
+	c.AddVersion("")
+	text := "Example CLI v1.2.3\nRevision: abc123 (dirty)\nCommit time: 2024-01-02T03:04:05Z\nGo version: go1.21.0\n"
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--version"}, text},
+		{[]string{"version"}, text},
+		{[]string{"play", "--version"}, text},
+		{[]string{"version", "--output", "json"}, "{\n  \"name\": \"Example CLI\",\n  \"version\": \"v1.2.3\",\n  \"revision\": \"abc123\",\n  \"dirty\": true,\n  \"commit_time\": \"2024-01-02T03:04:05Z\",\n  \"go_version\": \"go1.21.0\"\n}\n"},
+		{[]string{"--version", "--output", "jsonl"}, "{\"name\":\"Example CLI\",\"version\":\"v1.2.3\",\"revision\":\"abc123\",\"dirty\":true,\"commit_time\":\"2024-01-02T03:04:05Z\",\"go_version\":\"go1.21.0\"}\n"},
+	}
+	for _, tt := range tests {
+		code, out := runWithStdout(t, c, append([]string{"test"}, tt.args...))
+		if code != 0 || out != tt.want {
+			t.Errorf("%v: got %d %q want 0 %q\n", tt.args, code, out, tt.want)
+		}
+	}
+	assertExitCode(t, c, []string{"test", "version", "--output", "xml"}, 1)
+	assertExitCode(t, c, []string{"test", "play", "arena", "--version"}, 1)
+	if res := c.Parse([]string{"play", "-d", "2", "--version"}); !res.Version || res.Err != nil {
+		t.Errorf("got %v %v want version\n", res.Version, res.Err)
+	}
+
+	c = newTestCLI()
+	c.AddOutputFlags()
+	c.AddVersion("2.0.0")
+	want := "commit_time: \"2024-01-02T03:04:05Z\"\ndirty: true\ngo_version: \"go1.21.0\"\nname: \"Example CLI\"\nrevision: \"abc123\"\nversion: \"2.0.0\"\n"
+	for _, args := range [][]string{
+		{"--output", "yaml", "--version"},
+		{"version", "--output", "yaml"},
+	} {
+		code, out := runWithStdout(t, c, append([]string{"test"}, args...))
+		if code != 0 || out != want {
+			t.Errorf("%v: got %d %q want 0 %q\n", args, code, out, want)
+		}
+	}
+	tests = []struct {
+		args []string
+		want string
+	}{
+		{[]string{"version", "--output", "csv"}, "name,version,revision,dirty,commit_time,go_version\nExample CLI,2.0.0,abc123,true,2024-01-02T03:04:05Z,go1.21.0\n"},
+		{[]string{"--output", "template={{.Version}}", "--version"}, "2.0.0"},
+	}
+	for _, tt := range tests {
+		code, out := runWithStdout(t, c, append([]string{"test"}, tt.args...))
+		if code != 0 || out != tt.want {
+			t.Errorf("%v: got %d %q want 0 %q\n", tt.args, code, out, tt.want)
+		}
+	}
+}
+
+func TestHelp(t *testing.T) {
+	c := createCLI()
+	c.AddHelpTopic("environment", "Environment variables", "GAME_DIR is a directory with maps")
+
+	_, main := runWithStdout(t, c, []string{"test", "--help"})
+	if !strings.Contains(main, "Help topics:\n  environment") || !strings.Contains(main, "  help") {
+		t.Errorf("got %q want help command and topics listed\n", main)
+	}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"help"}, main},
+		{[]string{"help", "environment"}, "GAME_DIR is a directory with maps\n"},
+	}
+	for _, tt := range tests {
+		code, out := runWithStdout(t, c, append([]string{"test"}, tt.args...))
+		if code != 0 || out != tt.want {
+			t.Errorf("%v: got %d %q want 0 %q\n", tt.args, code, out, tt.want)
+		}
+	}
+
+	_, play := runWithStdout(t, c, []string{"test", "play", "--help"})
+	for _, args := range [][]string{
+		{"help", "play"},
+		{"play", "-l", "1", "--help"},
+		{"play", "-h", "--level", "x"},
+	} {
+		code, out := runWithStdout(t, c, append([]string{"test"}, args...))
+		if code != 0 || out != play || !strings.Contains(out, "Starting level") {
+			t.Errorf("%v: got %d %q want 0 %q\n", args, code, out, play)
+		}
+	}
+	assertExitCode(t, c, []string{"test", "play", "-l", "1", "--", "--help"}, 1)
+	for _, args := range [][]string{
+		{"play", "-l", "1", "arena", "-h"},
+		{"play", "arena", "-h", "--level", "x"},
+		{"play", "arena", "--level", "x", "--help"},
+	} {
+		code, out := runWithStdout(t, c, append([]string{"test"}, args...))
+		if code != 0 || out != play {
+			t.Errorf("%v: got %d %q want 0 %q\n", args, code, out, play)
+		}
+	}
+	assertExitCode(t, c, []string{"test", "play", "-l", "1", "arena", "--level", "-h"}, 1)
+
+	var msg string
+	say := c.AddCmd("say", "Says", func(c *CLI) int {
+		msg = c.Flag("msg")
+		return 0
+	})
+	say.AddFlag("msg", "m", "TEXT", "Message", TypeString, nil)
+	code, out := runWithStdout(t, c, []string{"test", "say", "--msg", "--help"})
+	if code != 0 || msg != "--help" || strings.Contains(out, "Usage") {
+		t.Errorf("got %d %q %q want 0 and --help passed as value\n", code, msg, out)
+	}
+
+	_, out = runWithStdout(t, c, []string{"test", "help", "play", "environment"})
+	if out != play+"GAME_DIR is a directory with maps\n" {
+		t.Errorf("got %q want help of play and environment\n", out)
+	}
+	assertExitCode(t, c, []string{"test", "help", "enviroment"}, 1)
+
+	c.AddCmd("help", "Custom help", func(c *CLI) int { return 3 })
+	assertExitCode(t, c, []string{"test", "help", "play"}, 3)
+
+	c = createCLI()
+	c.AddHelpTopic("play", "Playing", "Text")
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "already used by help topic play") {
+		t.Errorf("got %v want error about help topic\n", err)
+	}
+}
+
+func TestCategories(t *testing.T) {
+	c := newTestCLI()
+	c.AddCategory("game", "Game commands")
+	c.AddCmd("stop", "Stops", h).SetCategory("game")
+	c.AddCmd("start", "Starts", h).SetCategory("game")
+	c.AddCmd("setup", "Sets up", h)
+	c.AddCmd("debug", "Debugs", h).SetCategory("Other")
+	c.AddCmd("secret", "Hidden", h).SetCategory("Secret")
+	c.GetCmd("secret").SetHidden(true)
+
+	want := "Commands:\n  setup\tSets up\n  help\tPrints help for a command or topic\n\n" +
+		"Game commands:\n  start\tStarts\n  stop\tStops\n\n" +
+		"Other:\n  debug\tDebugs\n"
+	_, out := runWithStdout(t, c, []string{"test"})
+	if !strings.Contains(out, want) {
+		t.Errorf("got %q want it to contain %q\n", out, want)
+	}
+
+	c.SetSortCmds(false)
+	_, out = runWithStdout(t, c, []string{"test"})
+	if !strings.Contains(out, "Game commands:\n  stop\tStops\n  start\tStarts\n") {
+		t.Errorf("got %q want commands in the order they were added\n", out)
+	}
+
+	got := c.CompleteItems("s")
+	wantItems := []Completion{
+		{"setup", "Sets up", "Commands"},
+		{"start", "Starts", "Game commands"},
+		{"stop", "Stops", "Game commands"},
+	}
+	if !reflect.DeepEqual(got, wantItems) {
+		t.Errorf("got %v want %v\n", got, wantItems)
+	}
+}
+
+func TestExamples(t *testing.T) {
+	var runs int
+	c := createCLI()
+	c.AddCmd("count", "Counts runs", func(c *CLI) int {
+		runs++
+		return 0
+	}).AddExample("count", "")
+	cmd := c.GetCmd("play")
+	cmd.AddExample("play -l 1 arena 3", "Play on arena with 3 opponents")
+	cmd.AddExample("play --level 2 --difficulty 5 \"big map\" 1", "")
+	cmd.AddExample("play -h", "")
+	c.GetCmd("command").AddExample("command -t title -i cli_test.go", "")
+
+	if err := c.ValidateExamples(); err != nil {
+		t.Errorf("got %v want nil\n", err)
+	}
+	if runs != 0 {
+		t.Errorf("got %d runs want 0\n", runs)
+	}
+
+	_, out := runWithStdout(t, c, []string{"test", "play", "--help"})
+	want := "\nExamples:\n  # Play on arena with 3 opponents\n  test play -l 1 arena 3\n\n  test play --level 2 --difficulty 5 \"big map\" 1\n\n  test play -h\n"
+	if !strings.HasSuffix(out, want) {
+		t.Errorf("got %q want it to end with %q\n", out, want)
+	}
+
+	cmd.AddExample("play -l x arena 3", "")
+	cmd.AddExample("command -t title -i cli_test.go", "")
+	cmd.AddExample("play --unknown -l 1 arena 3", "")
+	err := c.ValidateExamples()
+	var ee *ExampleError
+	var ive *InvalidValueError
+	if !errors.As(err, &ee) || !errors.As(err, &ive) || strings.Count(err.Error(), "\n") != 2 {
+		t.Errorf("got %v want 3 ExampleErrors\n", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	var runs int
+	c := createCLI()
+	c.AddGlobalFlag("verbose", "v", "", "Verbose", TypeBool, nil)
+	c.GetCmd("play").AddAlias("p")
+	c.GetCmd("play").AddPostValidation(func(c *CLI) error {
+		runs++
+		return nil
+	})
+	c.AddPreRun(func(ctx context.Context, c *CLI) error {
+		runs++
+		return nil
+	})
+
+	res := c.Parse([]string{"-v", "p", "-l", "3", "--difficulty", "2", "--", "arena", "5", "1", "extra", "more"})
+	if res.Err != nil || res.Command != "play" || res.Help {
+		t.Errorf("got %v %q %v want nil play false\n", res.Err, res.Command, res.Help)
+	}
+	wantFlags := map[string]FlagValue{
+		"level":      {"3", SourceAlias},
+		"difficulty": {"2", SourceName},
+		"verbose":    {"true", SourceAlias},
+		"all":        {"", SourceDefault},
+	}
+	if !reflect.DeepEqual(res.Flags, wantFlags) {
+		t.Errorf("got %v want %v\n", res.Flags, wantFlags)
+	}
+	wantArgs := map[string]string{"map": "arena", "opponents": "5", "foes": "1", "all": "extra"}
+	if !reflect.DeepEqual(res.Args, wantArgs) {
+		t.Errorf("got %v want %v\n", res.Args, wantArgs)
+	}
+	if !reflect.DeepEqual(res.Passthrough, []string{"more"}) {
+		t.Errorf("got %v want args after -- that are not arguments\n", res.Passthrough)
+	}
+
+	res = c.Parse([]string{"play", "-l", "x", "arena", "5"})
+	var ive *InvalidValueError
+	if res.Command != "play" || !errors.As(res.Err, &ive) || ive.Flag != "level" {
+		t.Errorf("got %q %v want play InvalidValueError\n", res.Command, res.Err)
+	}
+	res = c.Parse([]string{"plya"})
+	var uce *UnknownCommandError
+	if res.Command != "" || res.Help || !errors.As(res.Err, &uce) || uce.Suggestion != "play" {
+		t.Errorf("got %q %v %v want UnknownCommandError without help\n", res.Command, res.Help, res.Err)
+	}
+	res = c.Parse([]string{"overwrite_arg", "-o"})
+	if res.Err != nil || res.Flags["overwrite"].Value != "true" {
+		t.Errorf("got %v %v want no error when flag makes argument not required\n", res.Err, res.Flags["overwrite"])
+	}
+	if !c.GetCmd("overwrite_arg").GetArg("notrequired").IsRequired() {
+		t.Errorf("got argument changed in definition want it changed only in a copy\n")
+	}
+	res = c.Parse([]string{"play", "--help"})
+	if res.Command != "play" || !res.Help || res.Err != nil {
+		t.Errorf("got %q %v %v want play help\n", res.Command, res.Help, res.Err)
+	}
+	if runs != 0 || len(c.parsedFlags) != 0 {
+		t.Errorf("got %d runs and %v flags want none\n", runs, c.parsedFlags)
+	}
+}
+
+func TestConcurrentRuns(t *testing.T) {
+	c := newTestCLI()
+	c.AddGlobalFlag("verbose", "v", "", "Verbose", TypeBool, nil)
+	cmd := c.AddCmdE("greet", "Greets", func(ctx context.Context, c *CLI) error {
+		if c.Arg("name") != c.Flag("expect") || c.Flag("verbose") != c.Flag("expect-verbose") {
+			return fmt.Errorf("got %q %q want %q %q", c.Arg("name"), c.Flag("verbose"), c.Flag("expect"), c.Flag("expect-verbose"))
+		}
+		return nil
+	})
+	cmd.AddFlag("expect", "", "NAME", "Expected name", TypeString, nil)
+	cmd.AddFlag("expect-verbose", "", "BOOL", "Expected verbose", TypeString|Required, nil)
+	cmd.AddArg("name", "NAME", "Name", TypeString)
+	cmd.GetArg("name").SetPrompt(PromptText, "Name")
+
+	f := openNull(t)
+	if code := c.RunArgs([]string{"-v", "greet", "--expect", "bob", "--expect-verbose", "true", "bob"}, f, f); code != 0 {
+		t.Errorf("got %d want 0\n", code)
+	}
+	if code := c.RunArgs([]string{"greet", "--expect-verbose", "false"}, f, f); code != 0 {
+		t.Errorf("got %d want 0 when values of previous run are not kept\n", code)
+	}
+
+	var wg sync.WaitGroup
+	codes := make([]int, 20)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n := fmt.Sprintf("user%d", i)
+			args := []string{"greet", "--expect", n, "--expect-verbose", "false", n}
+			if i%2 == 0 {
+				args = []string{"--verbose", "greet", "--expect", n, "--expect-verbose", "true", n}
+			}
+			codes[i] = c.RunArgs(args, f, f)
+		}(i)
+	}
+	wg.Wait()
+	for i, code := range codes {
+		if code != 0 {
+			t.Errorf("run %d: got %d want 0\n", i, code)
+		}
+	}
+}
+
+func TestShell(t *testing.T) {
+	var names []string
+	c := newTestCLI()
+	cmd := c.AddCmd("start", "Starts", func(c *CLI) int {
+		names = append(names, c.Flag("user"))
+		return 0
+	})
+	cmd.AddFlag("user", "u", "USER", "User name", TypeString|Required, nil)
+	cmd.GetFlag("user").SetPrompt(PromptText, "User")
+	c.AddCmd("fail", "Fails", func(c *CLI) int { return 4 })
+	c.AddShellCmd("names", "Prints names", func(c *CLI, args []string) int {
+		fmt.Fprintf(c.GetStdout(), strings.Join(names, ",")+"\n")
+		return 0
+	})
+	c.SetInteractive(true)
+	c.SetShellPrompt("100%$ ")
+
+	f := tempFile(t, "stdout")
+	null := openNull(t)
+
+	withStdin(t, c, "start -u \"bob smith\"\n\nstart\nalice\n!!\ncarol\n!1\n!9\nfail\nnames\nhistory\nexit 3\nstart -u dave\n")
+	code := c.RunShell(f, null)
+	if code != 3 {
+		t.Errorf("got %d want 3\n", code)
+	}
+	want := []string{"bob smith", "alice", "carol", "bob smith"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v want %v\n", names, want)
+	}
+	b, _ := ioutil.ReadFile(f.Name())
+	for _, s := range []string{
+		"100%$ start\n",
+		"bob smith,alice,carol,bob smith\n",
+		"    1  start -u \"bob smith\"\n    2  start\n    3  start\n    4  start -u \"bob smith\"\n    5  fail\n    6  names\n    7  history\n",
+	} {
+		if !strings.Contains(string(b), s) {
+			t.Errorf("got %q want it to contain %q\n", string(b), s)
+		}
+	}
+
+	withStdin(t, c, "fail\n")
+	if code := c.RunShell(null, null); code != 4 {
+		t.Errorf("got %d want exit code of the last command\n", code)
+	}
+
+	invalid := newTestCLI()
+	invalid.AddCmd("run", "Runs", h)
+	invalid.AddCmd("run", "Runs again", h)
+	withStdin(t, invalid, "run\nrun\n")
+	stderr := tempFile(t, "stderr")
+	invalid.RunShell(null, stderr)
+	b, _ = ioutil.ReadFile(stderr.Name())
+	if n := strings.Count(string(b), "Invalid definition"); n != 1 {
+		t.Errorf("got %d warnings about definition want 1\n", n)
+	}
+
+	c.AddHelpTopic("env", "Environment", "")
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", []string{"exit", "fail", "help", "history", "names", "quit", "start"}},
+		{"st", []string{"start"}},
+		{"start -", []string{"--no-input", "--user", "-u"}},
+		{"start --u", []string{"--user"}},
+		{"start ", nil},
+		{"help ", []string{"env", "fail", "start"}},
+		{"--no-input s", []string{"start"}},
+	}
+	for _, tt := range tests {
+		got := c.Complete(tt.line)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v want %v\n", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	env := map[string]string{"USER": "bob smith", "DIR": "/tmp", "EMPTY": ""}
+	getenv := func(n string) string {
+		return env[n]
+	}
+	tests := []struct {
+		s    string
+		env  bool
+		want []string
+	}{
+		{`start -u "bob smith" --x='a b'`, false, []string{"start", "-u", "bob smith", "--x=a b"}},
+		{`a\ b "c\"d" 'e\f' "g\\h" # comment`, false, []string{"a b", `c"d`, `e\f`, `g\h`}},
+		{"one \\\ntwo \"th\\\nree\"\n# comment\nfour", false, []string{"one", "two", "three", "four"}},
+		{`'' "" x`, false, []string{"", "", "x"}},
+		{`$USER "$USER" '$USER'`, false, []string{"$USER", "$USER", "$USER"}},
+		{`-u $USER "$DIR/${USER}x" '$USER' \$DIR $ a$EMPTY $EMPTY "$EMPTY" $1`, true, []string{"-u", "bob smith", "/tmp/bob smithx", "$USER", "$DIR", "$", "a", "", "$1"}},
+	}
+	for _, tt := range tests {
+		got, err := Split(tt.s)
+		if tt.env {
+			got, err = SplitEnv(tt.s, getenv)
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, %v want %q\n", tt.s, got, err, tt.want)
+		}
+	}
+
+	for _, s := range []string{`"abc`, `'abc`, `abc\`, `${USER`, `${US-ER}`} {
+		if _, err := SplitEnv(s, getenv); err == nil {
+			t.Errorf("%q: got nil want error\n", s)
+		}
+	}
+
+	args := []string{"start", "", "bob smith", "it's", "#x", "a\"b", "$HOME", "--x=a/b:c", "tab\there", "new\nline"}
+	s := Join(args)
+	if s != `start '' 'bob smith' 'it'\''s' '#x' 'a"b' '$HOME' --x=a/b:c 'tab	here' 'new`+"\n"+`line'` {
+		t.Errorf("got %s\n", s)
+	}
+	got, err := Split(s)
+	if err != nil || !reflect.DeepEqual(got, args) {
+		t.Errorf("got %q, %v want %q\n", got, err, args)
+	}
+}
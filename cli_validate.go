@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+)
+
+// typeFlags are flag bits that set the type of a flag. Exactly one of them
+// has to be set.
+var typeFlags = []struct {
+	bit  int32
+	name string
+}{
+	{TypeString, "TypeString"},
+	{TypePathFile, "TypePathFile"},
+	{TypeBool, "TypeBool"},
+	{TypeInt, "TypeInt"},
+	{TypeFloat, "TypeFloat"},
+	{TypeAlphanumeric, "TypeAlphanumeric"},
+	{TypeEmail, "TypeEmail"},
+	{TypeFQDN, "TypeFQDN"},
+	{TypePathDir, "TypePathDir"},
+	{TypePathRegularFile, "TypePathRegularFile"},
+	{TypeCount, "TypeCount"},
+}
+
+// DefinitionError describes a mistake in definition of commands, flags or
+// arguments. Command or Flag is empty when the error is not about one.
+type DefinitionError struct {
+	Command string
+	Flag    string
+	Reason  string
+}
+
+func (e *DefinitionError) Error() string {
+	s := ""
+	if e.Command != "" {
+		s += "command " + e.Command + ": "
+	}
+	if e.Flag != "" {
+		s += e.Flag + ": "
+	}
+	return s + e.Reason
+}
+
+// Validate checks definition of commands, flags and arguments for empty and
+// duplicated names, colliding aliases, invalid combinations of flag bits and
+// required arguments that are added after optional ones. All found mistakes
+// are returned as one error.
+func (c *CLI) Validate() error {
+	errs := append([]error{}, c.defErrs...)
+	add := func(cmd string, f string, r string) {
+		errs = append(errs, &DefinitionError{Command: cmd, Flag: f, Reason: r})
+	}
+
+	gfs := c.getGlobalFlagList()
+	gnames := make(map[string]string)
+	for _, f := range append(gfs, getSecretFileFlags(gfs)...) {
+		validateFlagNames(f, false, gnames, func(fl string, r string) { add("", fl, r) })
+		validateFlagBits(f, false, func(fl string, r string) { add("", fl, r) })
+	}
+	for _, f := range gfs {
+		if f.IsDeprecated() && f.GetReplacedBy() != "" && c.GetGlobalFlag(f.GetReplacedBy()) == nil {
+			add("", "flag --"+f.GetName(), "replacement global flag --"+f.GetReplacedBy()+" does not exist")
+		}
+	}
+
+	cnames := make(map[string]string)
+	for _, n := range c.GetSortedHelpTopics() {
+		cnames[n] = "help topic " + n
+	}
+	for n := range c.shellCmds {
+		cnames[n] = "shell command " + n
+	}
+	for _, n := range c.GetSortedCmds() {
+		cmd := c.GetCmd(n)
+		if n == "" {
+			add("", "", "command name is empty")
+		}
+		for _, a := range append([]string{n}, cmd.GetAliases()...) {
+			if o, ok := cnames[a]; ok && a != "" {
+				add(n, "", "name or alias "+a+" is already used by "+o)
+			}
+			cnames[a] = "command " + n
+		}
+		for _, err := range cmd.defErrs {
+			add(n, "", err.Error())
+		}
+
+		fn := func(fl string, r string) { add(n, fl, r) }
+		names := make(map[string]string)
+		fs := cmd.getFlagList()
+		for _, f := range append(fs, getSecretFileFlags(fs)...) {
+			validateFlagNames(f, false, names, fn)
+			validateFlagBits(f, false, fn)
+		}
+		for _, f := range gfs {
+			a := f.GetAlias()
+			if o, ok := names[a]; ok && a != "" && o != f.GetName() && cmd.GetFlag(f.GetName()) == nil {
+				add(n, "flag --"+o, "alias -"+a+" collides with global flag --"+f.GetName())
+			}
+		}
+		for _, f := range fs {
+			if f.IsDeprecated() && f.GetReplacedBy() != "" && cmd.GetFlag(f.GetReplacedBy()) == nil {
+				fn("flag --"+f.GetName(), "replacement flag --"+f.GetReplacedBy()+" does not exist")
+			}
+		}
+
+		anames := make(map[string]string)
+		optional := ""
+		for i := 0; i < cmd.argsIdx; i++ {
+			f := cmd.GetArg(cmd.argsOrder[i])
+			validateFlagNames(f, true, anames, fn)
+			validateFlagBits(f, true, fn)
+			if !f.IsRequired() && optional == "" {
+				optional = f.GetName()
+			}
+			if f.IsRequired() && optional != "" {
+				fn("argument "+f.GetName(), "required argument is added after optional argument "+optional)
+			}
+		}
+
+		exists := func(fl string) bool {
+			return cmd.GetFlag(fl) != nil || c.GetGlobalFlag(fl) != nil
+		}
+		for _, g := range cmd.groups {
+			for _, fl := range append([]string{g.flag}, g.flags...) {
+				if fl != "" && !exists(fl) {
+					fn("", "flag --"+fl+" used in flag rule does not exist")
+				}
+			}
+		}
+		for _, r := range cmd.rules {
+			if !exists(r.flag) {
+				fn("", "flag --"+r.flag+" used in requirement rule does not exist")
+			}
+			if cmd.GetFlag(r.target) == nil && cmd.GetArg(r.target) == nil {
+				fn("", "flag or argument "+r.target+" used in requirement rule does not exist")
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateFlagNames checks that flag or argument f has a name and that its
+// name and alias are not used by another one in names. -h and --help are
+// reserved.
+func validateFlagNames(f *CLIFlag, isArg bool, names map[string]string, add func(string, string)) {
+	n := f.GetName()
+	label := "flag --" + n
+	if isArg {
+		label = "argument " + n
+	}
+	if n == "" {
+		add(label, "name is empty")
+	}
+	if isArg {
+		if o, ok := names[n]; ok {
+			add(label, "name is already used by argument "+o)
+		}
+		names[n] = n
+		return
+	}
+	if n == "help" {
+		add(label, "name is reserved for help")
+	}
+	if f.GetAlias() == "h" {
+		add(label, "alias -h is reserved for help")
+	}
+	for _, a := range []string{n, f.GetAlias()} {
+		if a == "" {
+			continue
+		}
+		if o, ok := names[a]; ok && o != n {
+			add(label, "name or alias "+a+" is already used by flag --"+o)
+		}
+		names[a] = n
+	}
+}
+
+// validateFlagBits checks that bits set on flag or argument f can be used
+// together.
+func validateFlagBits(f *CLIFlag, isArg bool, add func(string, string)) {
+	label := "flag --" + f.GetName()
+	if isArg {
+		label = "argument " + f.GetName()
+	}
+	nf := f.GetNFlags()
+	has := func(b int32) bool {
+		return nf&b > 0
+	}
+
+	var types []string
+	for _, t := range typeFlags {
+		if has(t.bit) {
+			types = append(types, t.name)
+		}
+	}
+	if len(types) == 0 {
+		add(label, "type is not set")
+	}
+	if len(types) > 1 {
+		add(label, "conflicting types "+strings.Join(types, ", "))
+	}
+
+	anum := has(TypeAlphanumeric)
+	checks := []struct {
+		fail   bool
+		reason string
+	}{
+		{has(AllowDots) && !anum, "AllowDots requires TypeAlphanumeric"},
+		{has(AllowUnderscore) && !anum, "AllowUnderscore requires TypeAlphanumeric"},
+		{has(AllowHyphen) && !anum, "AllowHyphen requires TypeAlphanumeric"},
+		{has(AllowMany) && !anum && !has(TypeInt) && !has(TypeFloat), "AllowMany requires TypeInt, TypeFloat or TypeAlphanumeric"},
+		{(has(ManySeparatorColon) || has(ManySeparatorSemiColon)) && !has(AllowMany), "ManySeparatorColon and ManySeparatorSemiColon require AllowMany"},
+		{has(ManySeparatorColon) && has(ManySeparatorSemiColon), "ManySeparatorColon conflicts with ManySeparatorSemiColon"},
+		{has(MustExist) && !has(TypePathFile), "MustExist requires TypePathFile"},
+		{has(Required) && (has(TypeBool) || has(TypeCount)), "Required cannot be used with TypeBool or TypeCount"},
+		{has(Secret) && !f.IsRequireValue(), "Secret requires a type with a value"},
+		{has(AllowStdin) && !isArg, "AllowStdin can be used only with arguments"},
+		{has(StdinSeparatorNUL) && !has(AllowStdin), "StdinSeparatorNUL requires AllowStdin"},
+		{isArg && (has(TypeBool) || has(TypeCount)), "argument cannot be TypeBool or TypeCount"},
+	}
+	for _, ch := range checks {
+		if ch.fail {
+			add(label, ch.reason)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+)
+
+// Names of global flag and command added by AddVersion.
+const (
+	VersionFlag = "version"
+	VersionCmd  = "version"
+)
+
+// versionOutputFlag is the built-in global flag that sets output format of
+// version.
+var versionOutputFlag = NewCLIFlag(OutputFlag, "", "FORMAT", "Output format of version: text, json, jsonl, yaml, csv or template=TEMPLATE", TypeString, nil)
+
+// readBuildInfo is debug.ReadBuildInfo, replaced in tests.
+var readBuildInfo = debug.ReadBuildInfo
+
+// VersionInfo contains version of the application and information about how
+// it was built. Fields other than Version are empty when the binary was built
+// without module or VCS information.
+type VersionInfo struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Revision   string `json:"revision,omitempty"`
+	Dirty      bool   `json:"dirty,omitempty"`
+	CommitTime string `json:"commit_time,omitempty"`
+	GoVersion  string `json:"go_version,omitempty"`
+}
+
+// versionRequest is returned by parseFlags when --version was passed after
+// the command name. It carries the output format.
+type versionRequest struct {
+	format string
+}
+
+func (e *versionRequest) Error() string {
+	return "version requested"
+}
+
+// AddVersion sets version of the application to v and adds --version global
+// flag and version command that print it. When v is empty, version of the
+// main module from build info is used. Output format is set with global
+// --output flag, which is available even when AddOutputFlags is not called.
+func (c *CLI) AddVersion(v string) {
+	c.version = v
+	c.versionFlag = true
+	c.AddGlobalFlag(VersionFlag, "", "", "Print version and exit", TypeBool, nil)
+	if c.GetCmd(VersionCmd) == nil {
+		c.AddCmdE(VersionCmd, "Prints version", func(ctx context.Context, c *CLI) error {
+			return c.PrintVersion(c.GetStdout(), c.Flag(OutputFlag))
+		})
+	}
+}
+
+// GetVersionInfo returns version of the application along with information
+// from runtime/debug.ReadBuildInfo: VCS revision, whether the working tree
+// was modified and time of the commit. Time of the build is not available in
+// build info.
+func (c *CLI) GetVersionInfo() VersionInfo {
+	vi := VersionInfo{Name: c.GetName(), Version: c.version}
+	bi, ok := readBuildInfo()
+	if !ok {
+		return vi
+	}
+	if vi.Version == "" {
+		vi.Version = bi.Main.Version
+	}
+	vi.GoVersion = bi.GoVersion
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			vi.Revision = s.Value
+		case "vcs.modified":
+			vi.Dirty = s.Value == "true"
+		case "vcs.time":
+			vi.CommitTime = s.Value
+		}
+	}
+	return vi
+}
+
+// PrintVersion prints version information to w in format f, which can be
+// text (default) or any of the Output formats. Table is the same as text.
+func (c *CLI) PrintVersion(w io.Writer, f string) error {
+	vi := c.GetVersionInfo()
+	switch of := strings.SplitN(f, "=", 2)[0]; {
+	case of == "" || of == "text" || of == OutputTable:
+		s := vi.Name + " " + vi.Version + "\n"
+		if vi.Revision != "" {
+			s += "Revision: " + vi.Revision
+			if vi.Dirty {
+				s += " (dirty)"
+			}
+			s += "\n"
+		}
+		if vi.CommitTime != "" {
+			s += "Commit time: " + vi.CommitTime + "\n"
+		}
+		if vi.GoVersion != "" {
+			s += "Go version: " + vi.GoVersion + "\n"
+		}
+		_, err := fmt.Fprint(w, s)
+		return err
+	case isOutputFormat(of):
+		return writeOutput(w, vi, f, "", "")
+	}
+	return &InvalidValueError{Flag: OutputFlag, Value: f, Reason: "unknown output format"}
+}
+
+// getVersionRequest returns versionRequest when global --version flag was
+// passed, which is in pointers nptrs and aptrs, or in gptrs that were passed
+// before the command name. cmd can be nil, otherwise --version and --output
+// flags of the command take precedence over global ones.
+func (c *CLI) getVersionRequest(cmd *CLICmd, nptrs map[string]interface{}, aptrs map[string]interface{}, gptrs [2]map[string]interface{}) *versionRequest {
+	if !c.versionFlag || (cmd != nil && cmd.GetFlag(VersionFlag) != nil) {
+		return nil
+	}
+	value := func(f *CLIFlag) string {
+		nv, av := getFlagValues(f, nptrs, aptrs)
+		if nv == "" && av == "" {
+			nv, av = getFlagValues(f, gptrs[0], gptrs[1])
+		}
+		if nv != "" {
+			return nv
+		}
+		return av
+	}
+	if value(c.GetGlobalFlag(VersionFlag)) != "true" {
+		return nil
+	}
+	vr := &versionRequest{}
+	if of := c.GetGlobalFlag(OutputFlag); of != nil && (cmd == nil || cmd.GetFlag(OutputFlag) == nil) {
+		vr.format = value(of)
+	}
+	return vr
+}
+
+// runVersion prints version in format f and returns exit code.
+func (c *CLI) runVersion(f string) int {
+	err := c.PrintVersion(c.GetStdout(), f)
+	if err != nil {
+		c.printError(err)
+		return c.GetExitCode(err)
+	}
+	return 0
+}
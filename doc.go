@@ -58,9 +58,33 @@ used to get a flag value.
         return 0
     }
 
+Handlers can also take a context and return an error. They are added with
+AddCmdE. The error is printed to stderr and the exit code is 1, unless it is
+an ExitError, which carries its own exit code. Exit codes of errors returned
+by the package can be changed with SetExitCode:
+
+    func SyncHandler(ctx context.Context, c *cli.CLI) error {
+        if c.Flag("dir") == "/" {
+            return &cli.ExitError{Code: 3, Err: errors.New("refusing to sync /")}
+        }
+        return nil
+    }
+
+    myCLI.AddCmdE("sync", "Synchronise files", SyncHandler)
+    myCLI.SetExitCode(cli.KindUnknownFlag, cli.ExitUsage)
+
+Context passed to the handler is cancelled on SIGINT, SIGTERM or SIGHUP and
+cleanup hooks added with AddCleanup run after it returns. Hooks and
+middlewares (AddPreRun, AddPostRun, AddFinally and Use) can be attached to
+CLI or a single command.
+
 And in the end of main() func:
 
         os.Exit(myCLI.Run(os.Stdout, os.Stderr))
 
+Check README.md for flag groups, requirement rules, prompts, secrets, response
+files, reading argument items from stdin, output formatting, logging and
+other features.
+
 */
 package cli
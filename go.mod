@@ -1,3 +1,3 @@
 module github.com/gen64/go-cli
 
-go 1.15
+go 1.21